@@ -3,19 +3,29 @@ package adapter
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
-	"os/signal"
-	"syscall"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 
 	"grunichat-onebot-adapter/internal/config"
 	"grunichat-onebot-adapter/internal/confirmation"
 	"grunichat-onebot-adapter/internal/converter"
 	"grunichat-onebot-adapter/internal/formatter"
+	"grunichat-onebot-adapter/internal/health"
+	"grunichat-onebot-adapter/internal/history"
+	"grunichat-onebot-adapter/internal/httpserver"
+	"grunichat-onebot-adapter/internal/i18n"
+	"grunichat-onebot-adapter/internal/media"
+	"grunichat-onebot-adapter/internal/metrics"
+	"grunichat-onebot-adapter/internal/middleware"
+	"grunichat-onebot-adapter/internal/offline"
+	"grunichat-onebot-adapter/internal/recorder"
 	"grunichat-onebot-adapter/internal/sender"
+	"grunichat-onebot-adapter/internal/shadow"
 	"grunichat-onebot-adapter/internal/types"
 	"grunichat-onebot-adapter/internal/websocket"
 )
@@ -26,44 +36,284 @@ type ModularAdapter struct {
 	logger              *logrus.Logger
 	onebotWS            websocket.IWebSocketManager
 	grunichatWS         websocket.IWebSocketManager
+	grunichatBuffer     *offline.BufferedManager // 未启用离线缓存时为nil
 	messageConverter    *converter.MessageConverter
 	wsFactory           *websocket.WebSocketManagerFactory
 	formatter           *formatter.MessageFormatter
 	confirmationManager confirmation.IConfirmationManager
 	onebotSender        sender.IMessageSender
+	rateLimitedSender   *sender.RateLimitedSender
+	apiClient           *sender.OneBotAPIClient
+	mediaRelay          *media.Relay
+	messageHistory      *history.Store
+	httpServer          *httpserver.Server
+	healthMonitor       *health.Monitor
+	trafficRecorder     *recorder.Recorder        // 未启用流量记录时为nil
+	dryRunRecorder      *recorder.Recorder        // 演练模式下记录本应发送的帧，未设置 dry_run.file 时为nil
+	mirrorClient        *shadow.MirrorClient      // 未配置流量镜像时为nil
+	inboundQueue        chan *types.OneBotMessage // 待转换的OneBot消息，按接收顺序处理
+	onebotReconnect     chan struct{}             // 通知重连监控立即重连
+	grunichatReconnect  chan struct{}
+	inboundPending      atomic.Int32       // 已入队但尚未处理完成的入站消息数
+	closing             atomic.Bool        // 开始关闭后不再接收新消息
+	cancel              context.CancelFunc // 停止 Launch 启动的后台协程
+	unregisterMetrics   func()             // 取消 Launch 注册的指标采集回调
+}
+
+// 适配器的可选项，用于嵌入到其他程序
+type Options struct {
+	OneBotTransport    websocket.IWebSocketManager // 代替默认的OneBot连接，为nil时按配置创建
+	GRUniChatTransport websocket.IWebSocketManager // 代替默认的GRUniChat连接，为nil时按配置创建
+	InboundHooks       []middleware.Middleware     // 在配置的入站阶段之后执行
+	OutboundHooks      []middleware.Middleware     // 在配置的出站阶段之后执行
 }
 
 // 创建模块化适配器
 func NewModularAdapter(cfg *config.Config, logger *logrus.Logger) *ModularAdapter {
+	return NewModularAdapterWithOptions(cfg, logger, Options{})
+}
+
+// 按可选项创建模块化适配器
+func NewModularAdapterWithOptions(cfg *config.Config, logger *logrus.Logger, opts Options) *ModularAdapter {
 	// 创建WebSocket工厂
 	wsFactory := websocket.NewWebSocketManagerFactory(cfg, logger)
 
 	// 创建WebSocket管理器
-	onebotWS := wsFactory.CreateOneBotManager()
-	grunichatWS := wsFactory.CreateGRUniChatManager()
+	onebotWS := opts.OneBotTransport
+	if onebotWS == nil {
+		onebotWS = wsFactory.CreateOneBotManager()
+	}
+	grunichatWS := opts.GRUniChatTransport
+	if grunichatWS == nil {
+		grunichatWS = wsFactory.CreateGRUniChatManager()
+	}
+
+	// 演练模式下可以改为接收生产实例镜像的流量，代替真实连接
+	var mirrorSource *shadow.MirrorSource
+	if cfg.DryRun.Enabled && cfg.DryRun.MirrorSource {
+		if cfg.HTTP.Enabled {
+			mirrorSource = shadow.NewMirrorSource(logger)
+			onebotWS = mirrorSource.OneBot()
+			grunichatWS = mirrorSource.GRUniChat()
+		} else {
+			logger.Warn("dry_run.mirror_source requires the HTTP server, connecting to OneBot and GRUniChat instead")
+		}
+	}
+
+	// 演练模式下发送的消息只写日志和记录
+	var dryRunRecorder *recorder.Recorder
+	if cfg.DryRun.Enabled {
+		var sink recorder.IFrameSink
+		if cfg.DryRun.File != "" {
+			rec, err := recorder.NewRecorder(cfg.DryRun.File, logger)
+			if err != nil {
+				logger.Errorf("Failed to open dry-run recording, suppressed messages will only be logged: %v", err)
+			} else {
+				dryRunRecorder = rec
+				sink = rec
+			}
+		}
+		onebotWS = shadow.NewDryRunManager(onebotWS, recorder.ConnectionOneBot, sink, logger)
+		grunichatWS = shadow.NewDryRunManager(grunichatWS, recorder.ConnectionGRUniChat, sink, logger)
+		logger.Warn("Dry-run mode enabled, no messages will be sent to QQ groups or GRUniChat")
+	}
+
+	// 启用流量记录时包装两个连接
+	var trafficRecorder *recorder.Recorder
+	if cfg.Recorder.Enabled {
+		rec, err := recorder.NewRecorder(cfg.Recorder.File, logger)
+		if err != nil {
+			logger.Errorf("Failed to initialize traffic recorder, traffic will not be recorded: %v", err)
+		} else {
+			trafficRecorder = rec
+			onebotWS = recorder.NewRecordingManager(onebotWS, recorder.ConnectionOneBot, rec)
+			grunichatWS = recorder.NewRecordingManager(grunichatWS, recorder.ConnectionGRUniChat, rec)
+			logger.Infof("Recording traffic to %s", cfg.Recorder.File)
+		}
+	}
+
+	// 配置流量镜像时将收到的帧转发给影子实例
+	var mirrorClient *shadow.MirrorClient
+	if cfg.Mirror.URL != "" {
+		mirrorClient = shadow.NewMirrorClient(cfg.Mirror.URL, cfg.Mirror.Token, cfg.Performance.MessageQueueSize, logger)
+		onebotWS = recorder.NewRecordingManager(onebotWS, recorder.ConnectionOneBot, mirrorClient)
+		grunichatWS = recorder.NewRecordingManager(grunichatWS, recorder.ConnectionGRUniChat, mirrorClient)
+	}
+
+	// 启用离线缓存时包装GRUniChat连接
+	var grunichatBuffer *offline.BufferedManager
+	if cfg.Offline.Enabled {
+		buffer, err := offline.NewBuffer(cfg.Offline.MaxMessages, time.Duration(cfg.Offline.MaxAge)*time.Second, cfg.Offline.File, logger)
+		if err != nil {
+			logger.Errorf("Failed to initialize offline buffer, messages will not be buffered: %v", err)
+		} else {
+			grunichatBuffer = offline.NewBufferedManager(grunichatWS, buffer, logger)
+			grunichatWS = grunichatBuffer
+		}
+	}
 
 	// 创建核心模块（需要按依赖顺序创建）
 	formatter := formatter.NewMessageFormatter(cfg, logger)
-	onebotSender := sender.NewOneBotMessageSender(onebotWS, logger)
+	var onebotSender sender.IMessageSender = sender.NewOneBotMessageSender(onebotWS, logger)
+
+	// 启用限流时包装发送器
+	var rateLimitedSender *sender.RateLimitedSender
+	if cfg.RateLimit.Enabled {
+		rateLimitedSender = sender.NewRateLimitedSender(onebotSender, cfg, logger, formatter.Catalog())
+		onebotSender = rateLimitedSender
+	}
+
+	apiClient := sender.NewOneBotAPIClient(onebotWS, logger)
+
+	// 创建媒体转发器（可选）
+	var mediaRelay *media.Relay
+	if cfg.Media.Enabled {
+		relay, err := media.NewRelay(cfg, logger, apiClient)
+		if err != nil {
+			logger.Errorf("Failed to initialize media relay, media will not be forwarded: %v", err)
+		} else {
+			mediaRelay = relay
+		}
+	}
+
+	// 创建消息历史存储（可选）
+	var messageHistory *history.Store
+	if cfg.History.Enabled {
+		store, err := history.NewStore(cfg.History.Dir, time.Duration(cfg.History.RetentionDays)*24*time.Hour, logger)
+		if err != nil {
+			logger.Errorf("Failed to initialize message history, messages will not be recorded: %v", err)
+		} else {
+			messageHistory = store
+		}
+	}
+
+	healthMonitor := health.NewMonitor(onebotWS, grunichatWS)
+
+	// 创建内置HTTP服务（可选）
+	var httpServer *httpserver.Server
+	if cfg.HTTP.Enabled {
+		httpServer = httpserver.NewServer(cfg, logger)
+		httpServer.Handle("/healthz", healthMonitor.LivenessHandler())
+		httpServer.Handle("/readyz", healthMonitor.ReadinessHandler())
+		if mediaRelay != nil {
+			httpServer.Handle("/media/", mediaRelay.Handler())
+		}
+		if messageHistory != nil {
+			httpServer.HandleAdmin("/admin/history", messageHistory.Handler())
+		}
+		if cfg.Metrics.Enabled {
+			httpServer.Handle("/metrics", metrics.Default.Handler())
+		}
+		if mirrorSource != nil {
+			httpServer.HandleAdmin("/shadow/mirror", mirrorSource.Handler())
+		}
+	}
+
 	confirmationManager := confirmation.NewCommandConfirmationManager(formatter, onebotSender, grunichatWS, logger)
-	messageConverter := converter.NewMessageConverter(cfg, logger, formatter, confirmationManager, onebotSender)
+	messageConverter := converter.NewMessageConverter(cfg, logger, formatter, confirmationManager, onebotSender, mediaRelay, messageHistory)
+	for _, hook := range opts.InboundHooks {
+		messageConverter.UseInbound(hook)
+	}
+	for _, hook := range opts.OutboundHooks {
+		messageConverter.UseOutbound(hook)
+	}
 
-	return &ModularAdapter{
+	adapter := &ModularAdapter{
 		config:              cfg,
 		logger:              logger,
 		onebotWS:            onebotWS,
 		grunichatWS:         grunichatWS,
+		grunichatBuffer:     grunichatBuffer,
 		messageConverter:    messageConverter,
 		wsFactory:           wsFactory,
 		formatter:           formatter,
 		confirmationManager: confirmationManager,
 		onebotSender:        onebotSender,
+		rateLimitedSender:   rateLimitedSender,
+		apiClient:           apiClient,
+		mediaRelay:          mediaRelay,
+		messageHistory:      messageHistory,
+		httpServer:          httpServer,
+		healthMonitor:       healthMonitor,
+		trafficRecorder:     trafficRecorder,
+		dryRunRecorder:      dryRunRecorder,
+		mirrorClient:        mirrorClient,
+		inboundQueue:        make(chan *types.OneBotMessage, cfg.Performance.MessageQueueSize),
+		onebotReconnect:     make(chan struct{}, 1),
+		grunichatReconnect:  make(chan struct{}, 1),
 	}
+	if httpServer != nil {
+		adapter.registerAdminRoutes(httpServer)
+	}
+
+	return adapter
 }
 
-// 启动适配器
+// 消息转换器
+func (adapter *ModularAdapter) Converter() *converter.MessageConverter {
+	return adapter.messageConverter
+}
+
+// 消息格式化器
+func (adapter *ModularAdapter) Formatter() *formatter.MessageFormatter {
+	return adapter.formatter
+}
+
+// 采集时更新按需读取的指标
+func (adapter *ModularAdapter) collectMetrics() {
+	metrics.PendingConfirmations.Set(float64(adapter.confirmationManager.GetPendingCount()))
+	metrics.QueueDepth.Set(float64(len(adapter.inboundQueue)), "inbound")
+	if adapter.rateLimitedSender != nil {
+		metrics.QueueDepth.Set(float64(adapter.rateLimitedSender.PendingCount()), "send")
+	}
+	if adapter.grunichatBuffer != nil {
+		metrics.QueueDepth.Set(float64(adapter.grunichatBuffer.PendingCount()), "offline")
+	}
+}
+
+// 启动适配器，阻塞到上下文取消后关闭适配器并返回
 func (adapter *ModularAdapter) Start(ctx context.Context) error {
+	if err := adapter.Launch(ctx); err != nil {
+		adapter.Shutdown()
+		return err
+	}
+
+	<-ctx.Done()
+	adapter.logger.Info("Context cancelled, shutting down...")
+	return adapter.Shutdown()
+}
+
+// 启动适配器后立即返回，不处理系统信号；启动期间取消 ctx 会中止连接，启动后由调用方调用 Shutdown 关闭
+func (adapter *ModularAdapter) Launch(callerCtx context.Context) error {
 	adapter.logger.Info("Starting GRUniChat-OneBot Modular Adapter")
+	// 后台协程在 Shutdown 发送完队列中的消息后才停止，不随调用方的上下文取消
+	ctx, cancel := context.WithCancel(context.WithoutCancel(callerCtx))
+	adapter.cancel = cancel
+	stopStartup := context.AfterFunc(callerCtx, cancel)
+	defer stopStartup()
+
+	// 指标注册表是进程级的，采集回调在 Shutdown 时取消注册，避免读取已关闭的适配器
+	adapter.unregisterMetrics = metrics.Default.OnCollect(adapter.collectMetrics)
+
+	// 启动内置HTTP服务
+	if adapter.httpServer != nil {
+		if err := adapter.httpServer.Start(); err != nil {
+			return err
+		}
+	}
+
+	// 启动限流发送循环
+	if adapter.rateLimitedSender != nil {
+		go adapter.rateLimitedSender.Run(ctx)
+	}
+
+	// 启动入站消息处理协程
+	go adapter.processInboundQueue(ctx)
+
+	// 启动流量镜像
+	if adapter.mirrorClient != nil {
+		go adapter.mirrorClient.Run(ctx)
+	}
 
 	// 连接OneBot
 	if err := adapter.connectOneBot(ctx); err != nil {
@@ -74,11 +324,20 @@ func (adapter *ModularAdapter) Start(ctx context.Context) error {
 	if err := adapter.connectGRUniChat(ctx); err != nil {
 		return err
 	}
+	if err := callerCtx.Err(); err != nil {
+		return fmt.Errorf("startup cancelled: %w", err)
+	}
 
-	adapter.logger.Info("Modular adapter started successfully")
+	// 启动重连监控，GRUniChat连接正常时继续重放上次中断的缓存消息
+	// 未启用离线缓存和 auto_reconnect 时GRUniChat保持仅OneBot模式，只响应管理接口的重连请求
+	go adapter.superviseConnection(ctx, "onebot", adapter.onebotWS, adapter.handleOneBotMessage, adapter.onebotReconnect, true, nil)
+	go adapter.superviseConnection(ctx, "grunichat", adapter.grunichatWS, adapter.grunichatHandler(ctx), adapter.grunichatReconnect, adapter.grunichatAutoReconnect(), adapter.replayBufferedMessages)
+
+	// 启动清理任务
+	go adapter.startCleanupTasks(ctx)
 
-	// 等待信号或上下文取消
-	return adapter.waitForShutdown(ctx)
+	adapter.logger.Info("Modular adapter started successfully")
+	return nil
 }
 
 // 连接OneBot
@@ -88,18 +347,24 @@ func (adapter *ModularAdapter) connectOneBot(ctx context.Context) error {
 
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
 		adapter.logger.Infof("Attempting to connect to OneBot (attempt %d/%d)", attempt, maxAttempts)
+		if attempt > 1 {
+			metrics.ReconnectAttemptsTotal.Inc("onebot")
+		}
 
+		// 先设置消息处理器，避免连接后立即收到的消息被丢弃
+		adapter.onebotWS.SetMessageHandler(adapter.handleOneBotMessage)
 		if err := adapter.onebotWS.Connect(ctx); err != nil {
 			adapter.logger.Errorf("Failed to connect to OneBot (attempt %d): %v", attempt, err)
 			if attempt < maxAttempts {
 				adapter.logger.Infof("Retrying in %v...", delay)
-				time.Sleep(delay)
+				select {
+				case <-ctx.Done():
+					return fmt.Errorf("connecting to OneBot cancelled: %w", ctx.Err())
+				case <-time.After(delay):
+				}
 			}
 			continue
 		}
-
-		// 设置消息处理器
-		adapter.onebotWS.SetMessageHandler(adapter.handleOneBotMessage)
 		break
 	}
 
@@ -114,20 +379,103 @@ func (adapter *ModularAdapter) connectOneBot(ctx context.Context) error {
 func (adapter *ModularAdapter) connectGRUniChat(ctx context.Context) error {
 	adapter.logger.Info("Connecting to GRUniChat")
 
+	// 先设置消息处理器，避免连接后立即收到的消息被丢弃
+	adapter.grunichatWS.SetMessageHandler(adapter.grunichatHandler(ctx))
 	if err := adapter.grunichatWS.Connect(ctx); err != nil {
+		// 认证被拒绝时重试也无法恢复，直接启动失败，避免以为已在转发消息
+		if errors.Is(err, websocket.ErrAuthFailed) {
+			return err
+		}
 		adapter.logger.Warnf("Failed to connect to GRUniChat: %v", err)
 		adapter.logger.Info("Continuing without GRUniChat connection (OneBot-only mode)")
 		return nil // 不返回错误，允许只连接OneBot
 	}
 
-	// 设置消息处理器
-	adapter.grunichatWS.SetMessageHandler(adapter.handleGRUniChatMessage)
+	adapter.replayBufferedMessages()
 	return nil
 }
 
+// GRUniChat断开后是否自动重连，离线缓存需要重连后才能重放，启用时始终自动重连
+func (adapter *ModularAdapter) grunichatAutoReconnect() bool {
+	return adapter.config.GRUniChat.AutoReconnect || adapter.grunichatBuffer != nil
+}
+
+// 监控连接，auto 为true时断开后按重连间隔自动重连；收到 trigger 信号时立即检查，onConnected 在连接正常时调用
+// 认证被拒绝时停止自动重连并在健康检查中报告原因，之后只响应 trigger 重连
+func (adapter *ModularAdapter) superviseConnection(
+	ctx context.Context,
+	name string,
+	ws websocket.IWebSocketManager,
+	handler func(message []byte),
+	trigger <-chan struct{},
+	auto bool,
+	onConnected func(),
+) {
+	var ticker *time.Ticker
+	var tick <-chan time.Time
+	if auto {
+		ticker = time.NewTicker(time.Duration(adapter.config.GRUniChat.ReconnectInterval) * time.Second)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	attempt := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-trigger:
+		}
+
+		if ws.IsConnected() {
+			attempt = 0
+			if onConnected != nil {
+				onConnected()
+			}
+			continue
+		}
+
+		attempt++
+		adapter.logger.Infof("Reconnecting to %s (attempt %d)", name, attempt)
+		metrics.ReconnectAttemptsTotal.Inc(name)
+
+		ws.SetMessageHandler(handler)
+		if err := ws.Connect(ctx); err != nil {
+			if errors.Is(err, websocket.ErrAuthFailed) {
+				// 重试无法恢复，修正服务端或凭据后通过 /admin/reconnect 重连
+				adapter.logger.Errorf("Stopped reconnecting to %s: %v", name, err)
+				adapter.healthMonitor.SetConnectionError(name, err)
+				tick = nil
+				continue
+			}
+			adapter.logger.Warnf("Failed to reconnect to %s: %v", name, err)
+			continue
+		}
+
+		adapter.logger.Infof("Reconnected to %s", name)
+		adapter.healthMonitor.SetConnectionError(name, nil)
+		if ticker != nil {
+			tick = ticker.C
+		}
+		attempt = 0
+		if onConnected != nil {
+			onConnected()
+		}
+	}
+}
+
+// 重放离线期间缓存的消息
+func (adapter *ModularAdapter) replayBufferedMessages() {
+	if adapter.grunichatBuffer != nil && adapter.grunichatBuffer.PendingCount() > 0 {
+		adapter.grunichatBuffer.Replay()
+	}
+}
+
 // 处理OneBot消息
 func (adapter *ModularAdapter) handleOneBotMessage(message []byte) {
 	adapter.logger.Debugf("Received OneBot message: %s", string(message))
+	adapter.healthMonitor.RecordOneBotMessage()
 
 	var onebot types.OneBotMessage
 	if err := json.Unmarshal(message, &onebot); err != nil {
@@ -135,33 +483,91 @@ func (adapter *ModularAdapter) handleOneBotMessage(message []byte) {
 		return
 	}
 
+	// API响应交给API客户端处理
+	if onebot.PostType == "" {
+		var response types.OneBotResponse
+		if err := json.Unmarshal(message, &response); err == nil && response.Echo != "" {
+			adapter.apiClient.HandleResponse(&response)
+			return
+		}
+	}
+
+	// 心跳和生命周期事件用于更新机器人在线状态
+	if onebot.PostType == "meta_event" {
+		var event types.OneBotMetaEvent
+		if err := json.Unmarshal(message, &event); err == nil {
+			adapter.healthMonitor.HandleMetaEvent(&event)
+		}
+		return
+	}
+
 	// 基本过滤
 	if onebot.PostType != "message" {
 		adapter.logger.Debugf("Message filtered out: %+v", onebot)
 		return
 	}
 
+	if adapter.closing.Load() {
+		adapter.logger.Debugf("Adapter is shutting down, dropping OneBot message %d", onebot.MessageID)
+		return
+	}
+
+	// 放入入站队列，在独立协程中转换，避免阻塞读取协程（转换过程中可能需要等待API响应）
+	adapter.inboundPending.Add(1)
+	select {
+	case adapter.inboundQueue <- &onebot:
+	default:
+		adapter.inboundPending.Add(-1)
+		adapter.logger.Warnf("Inbound queue is full, dropping OneBot message %d from user %d", onebot.MessageID, onebot.UserID)
+	}
+}
+
+// 按顺序处理入站队列中的OneBot消息
+func (adapter *ModularAdapter) processInboundQueue(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case onebot := <-adapter.inboundQueue:
+			adapter.forwardToGRUniChat(ctx, onebot)
+			adapter.inboundPending.Add(-1)
+		}
+	}
+}
+
+// 转换OneBot消息并发送到GRUniChat
+func (adapter *ModularAdapter) forwardToGRUniChat(ctx context.Context, onebot *types.OneBotMessage) {
 	// 转换消息
-	gruniMsg := adapter.messageConverter.OneBotToGRUniChat(&onebot)
+	gruniMsg := adapter.messageConverter.OneBotToGRUniChat(ctx, onebot)
 	if gruniMsg == nil {
 		return // 消息被过滤或已处理（如确认命令）
 	}
 
-	// 发送到GRUniChat
-	if adapter.grunichatWS.IsConnected() {
-		if err := adapter.grunichatWS.SendMessage(gruniMsg); err != nil {
-			adapter.logger.Errorf("Failed to send message to GRUniChat: %v", err)
-		} else {
-			adapter.logger.Debugf("Sent message to GRUniChat: %+v", gruniMsg)
-		}
+	// 发送到GRUniChat，启用离线缓存时未连接的消息会进入缓存
+	if err := adapter.grunichatWS.SendMessage(gruniMsg); err != nil {
+		adapter.logger.Warnf("Message not forwarded to GRUniChat: %v", err)
+		metrics.SendFailuresTotal.Inc("grunichat")
 	} else {
-		adapter.logger.Debug("GRUniChat not connected, message not forwarded")
+		adapter.logger.Debugf("Sent message to GRUniChat: %+v", gruniMsg)
+	}
+}
+
+// 返回使用 ctx 处理GRUniChat消息的处理器
+func (adapter *ModularAdapter) grunichatHandler(ctx context.Context) func(message []byte) {
+	return func(message []byte) {
+		adapter.handleGRUniChatMessage(ctx, message)
 	}
 }
 
 // 处理GRUniChat消息
-func (adapter *ModularAdapter) handleGRUniChatMessage(message []byte) {
+func (adapter *ModularAdapter) handleGRUniChatMessage(ctx context.Context, message []byte) {
 	adapter.logger.Debugf("Received GRUniChat message: %s", string(message))
+	adapter.healthMonitor.RecordGRUniChatMessage()
+
+	if adapter.closing.Load() {
+		adapter.logger.Debug("Adapter is shutting down, dropping GRUniChat message")
+		return
+	}
 
 	var gruni types.GRUniChatMessage
 	if err := json.Unmarshal(message, &gruni); err != nil {
@@ -170,26 +576,7 @@ func (adapter *ModularAdapter) handleGRUniChatMessage(message []byte) {
 	}
 
 	// 转换并发送到OneBot
-	adapter.messageConverter.GRUniChatToOneBot(&gruni)
-}
-
-// 等待关闭信号
-func (adapter *ModularAdapter) waitForShutdown(ctx context.Context) error {
-	// 创建信号通道
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
-	// 启动清理任务
-	go adapter.startCleanupTasks(ctx)
-
-	select {
-	case <-ctx.Done():
-		adapter.logger.Info("Context cancelled, shutting down...")
-	case sig := <-sigChan:
-		adapter.logger.Infof("Received signal %v, shutting down...", sig)
-	}
-
-	return adapter.shutdown()
+	adapter.messageConverter.GRUniChatToOneBot(ctx, &gruni)
 }
 
 // 启动清理任务
@@ -203,15 +590,55 @@ func (adapter *ModularAdapter) startCleanupTasks(ctx context.Context) {
 			return
 		case <-ticker.C:
 			adapter.confirmationManager.CleanupExpiredCommands()
+			adapter.messageConverter.CleanupExpiredState()
+			if adapter.mediaRelay != nil {
+				adapter.mediaRelay.Cleanup()
+			}
+			if adapter.messageHistory != nil {
+				adapter.messageHistory.Cleanup()
+			}
 		}
 	}
 }
 
-// 关闭适配器
-func (adapter *ModularAdapter) shutdown() error {
+// 关闭适配器：停止接收新消息，在超时时间内发送完队列中的消息并通知下线，然后关闭连接
+func (adapter *ModularAdapter) Shutdown() error {
+	if !adapter.closing.CompareAndSwap(false, true) {
+		return nil // 已经在关闭
+	}
 	adapter.logger.Info("Shutting down modular adapter...")
+	deadline := time.Now().Add(time.Duration(adapter.config.Shutdown.Timeout) * time.Second)
+
+	// 关闭HTTP服务
+	if adapter.httpServer != nil {
+		shutdownCtx, cancel := context.WithDeadline(context.Background(), deadline)
+		if err := adapter.httpServer.Shutdown(shutdownCtx); err != nil {
+			adapter.logger.Warnf("Failed to shut down HTTP server: %v", err)
+		}
+		cancel()
+	}
+
+	// 取消待确认的命令并通知下线，然后等待队列中的消息发送完成
+	adapter.cancelPendingConfirmations()
+	if adapter.config.Shutdown.NotifyGroups && adapter.onebotWS.IsConnected() {
+		for _, groupID := range adapter.messageConverter.Filter().ServiceGroups() {
+			adapter.onebotSender.SendGroupMessage(groupID, adapter.formatter.Localize(groupID, i18n.KeyShutdownNotice, nil))
+		}
+	}
+	adapter.drain(deadline)
+	if adapter.config.Shutdown.NotifyGRUniChat {
+		adapter.sendGoodbye()
+	}
+
+	// 停止后台协程（重连监控、发送循环、清理任务等）
+	if adapter.cancel != nil {
+		adapter.cancel()
+	}
+	if adapter.unregisterMetrics != nil {
+		adapter.unregisterMetrics()
+	}
 
-	// 关闭WebSocket连接
+	// 关闭WebSocket连接（发送关闭帧）
 	if adapter.onebotWS != nil {
 		adapter.onebotWS.Close()
 	}
@@ -219,6 +646,86 @@ func (adapter *ModularAdapter) shutdown() error {
 		adapter.grunichatWS.Close()
 	}
 
+	// 关闭消息历史存储
+	if adapter.messageHistory != nil {
+		if err := adapter.messageHistory.Close(); err != nil {
+			adapter.logger.Warnf("Failed to close message history: %v", err)
+		}
+	}
+
+	// 关闭流量记录
+	if adapter.trafficRecorder != nil {
+		adapter.trafficRecorder.Close()
+	}
+	if adapter.dryRunRecorder != nil {
+		adapter.dryRunRecorder.Close()
+	}
+
 	adapter.logger.Info("Modular adapter shutdown complete")
 	return nil
 }
+
+// 取消所有待确认的命令，并在群内通知发起人
+func (adapter *ModularAdapter) cancelPendingConfirmations() {
+	pending := adapter.confirmationManager.ListPending()
+	for _, command := range pending {
+		adapter.confirmationManager.CancelPending(command.UserID, command.GroupID)
+	}
+	if len(pending) > 0 {
+		adapter.logger.Infof("Cancelled %d pending command confirmations", len(pending))
+	}
+}
+
+// 等待入站队列、限流发送队列和离线缓存清空，超过 deadline 时放弃剩余的消息
+func (adapter *ModularAdapter) drain(deadline time.Time) {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		pending := adapter.pendingOutgoing()
+		if pending == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			adapter.logger.Warnf("Shutdown timeout reached, %d queued messages were not sent", pending)
+			return
+		}
+		<-ticker.C
+	}
+}
+
+// 尚未发送的消息数；连接断开时对应队列无法发送，不计入（配置了 offline.file 时离线缓存在下次启动时重放）
+func (adapter *ModularAdapter) pendingOutgoing() int {
+	pending := int(adapter.inboundPending.Load())
+	if adapter.rateLimitedSender != nil && adapter.onebotWS.IsConnected() {
+		pending += adapter.rateLimitedSender.PendingCount()
+	}
+	if adapter.grunichatBuffer != nil && adapter.grunichatWS.IsConnected() {
+		adapter.replayBufferedMessages()
+		pending += adapter.grunichatBuffer.PendingCount()
+	}
+	return pending
+}
+
+// 向GRUniChat发送下线事件
+func (adapter *ModularAdapter) sendGoodbye() {
+	if !adapter.grunichatWS.IsConnected() {
+		return
+	}
+
+	clientID := adapter.config.GRUniChat.ClientID
+	goodbye := &types.GRUniChatMessage{
+		From:        clientID,
+		Type:        "event",
+		TotalID:     uuid.New().String(),
+		CurrentTime: time.Now().Format("2006-01-02 15:04:05"),
+		Body: types.GRUniChatBody{
+			Sender:      clientID,
+			EventDetail: adapter.formatter.Localize(0, i18n.KeyShutdownNotice, nil),
+		},
+		Extra: map[string]interface{}{"status": "offline"},
+	}
+	if err := adapter.grunichatWS.SendMessage(goodbye); err != nil {
+		adapter.logger.Warnf("Failed to send offline event to GRUniChat: %v", err)
+	}
+}
@@ -0,0 +1,250 @@
+package adapter
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"grunichat-onebot-adapter/internal/config"
+	"grunichat-onebot-adapter/internal/health"
+	"grunichat-onebot-adapter/internal/sim/grunichatsim"
+	"grunichat-onebot-adapter/internal/sim/onebotsim"
+	"grunichat-onebot-adapter/internal/types"
+	"grunichat-onebot-adapter/internal/websocket"
+)
+
+const (
+	testGroupID = 100
+	testTimeout = 3 * time.Second
+)
+
+// 连接到两个模拟服务端的适配器
+type testHarness struct {
+	adapter   *ModularAdapter
+	config    *config.Config
+	onebot    *onebotsim.Server
+	grunichat *grunichatsim.Server
+}
+
+// 测试配置：连接模拟服务端，重连间隔1秒，只服务 testGroupID
+func newTestConfig(onebotURL, grunichatURL string) *config.Config {
+	cfg := config.Default()
+	cfg.OneBot.WebSocketURL = onebotURL
+	cfg.GRUniChat.URL = grunichatURL
+	cfg.GRUniChat.ReconnectInterval = 1
+	cfg.GRUniChat.MaxReconnectAttempts = 1
+	cfg.GRUniChat.AutoReconnect = true
+	cfg.Filter.ServiceGroups = []int64{testGroupID}
+	return cfg
+}
+
+func newTestLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+// 启动两个模拟服务端，测试结束时关闭；启动适配器前可修改 h.config 和模拟服务端
+func newTestHarness(t *testing.T) *testHarness {
+	t.Helper()
+	h := &testHarness{
+		onebot:    onebotsim.NewServer(),
+		grunichat: grunichatsim.NewServer(),
+	}
+	t.Cleanup(h.onebot.Close)
+	t.Cleanup(h.grunichat.Close)
+	h.config = newTestConfig(h.onebot.URL(), h.grunichat.URL())
+	return h
+}
+
+// 使用默认测试配置启动模拟服务端和适配器
+func startTestAdapter(t *testing.T) *testHarness {
+	t.Helper()
+	h := newTestHarness(t)
+	h.start(t)
+	return h
+}
+
+// 按 h.config 启动适配器并等待连接，测试结束时关闭
+func (h *testHarness) start(t *testing.T) {
+	t.Helper()
+	h.adapter = NewModularAdapter(h.config, newTestLogger())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- h.adapter.Start(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Error("adapter did not shut down")
+		}
+	})
+
+	h.waitConnected(t)
+}
+
+// 等待两端连接就绪
+func (h *testHarness) waitConnected(t *testing.T) {
+	t.Helper()
+	if err := h.onebot.WaitForConnection(testTimeout); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.grunichat.WaitForClient(testTimeout); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, "adapter connected", func() bool {
+		return h.adapter.onebotWS.IsConnected() && h.adapter.grunichatWS.IsConnected()
+	})
+}
+
+// 推送一条服务群聊消息
+func (h *testHarness) pushGroupMessage(t *testing.T, nickname, message string) {
+	t.Helper()
+	if _, err := h.onebot.PushGroupMessage(onebotsim.GroupMessage{
+		GroupID:  testGroupID,
+		UserID:   12345,
+		Nickname: nickname,
+		Message:  message,
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 轮询等待条件满足
+func waitUntil(t *testing.T, what string, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGroupMessageRelayedToGRUniChat(t *testing.T) {
+	h := startTestAdapter(t)
+
+	h.pushGroupMessage(t, "Steve", "hello")
+	message, err := h.grunichat.WaitForNextMessage("chat", testTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.Body.Sender != "Steve" {
+		t.Errorf("sender = %q, want Steve", message.Body.Sender)
+	}
+	if message.Body.ChatMessage != "hello" {
+		t.Errorf("chat message = %q, want hello", message.Body.ChatMessage)
+	}
+}
+
+func TestAPICallsCorrelateEchoes(t *testing.T) {
+	h := startTestAdapter(t)
+	// 响应数据回显请求参数，并发调用时每个调用方只能收到自己请求的响应
+	h.onebot.OnAction("get_msg", func(action *onebotsim.Action) *types.OneBotResponse {
+		return &types.OneBotResponse{Status: "ok", Data: action.Params["message_id"]}
+	})
+
+	const calls = 5
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			response, err := h.adapter.apiClient.CallAction(context.Background(), "get_msg", map[string]interface{}{"message_id": id})
+			if err != nil {
+				t.Errorf("CallAction(%d) error = %v", id, err)
+				return
+			}
+			if response.Data != float64(id) {
+				t.Errorf("CallAction(%d) data = %v", id, response.Data)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := len(h.onebot.ActionsNamed("get_msg")); got != calls {
+		t.Errorf("get_msg calls = %d, want %d", got, calls)
+	}
+}
+
+func TestOneBotReconnectsAfterDisconnect(t *testing.T) {
+	h := startTestAdapter(t)
+
+	h.onebot.DisconnectAll()
+	waitUntil(t, "OneBot disconnect detected", func() bool {
+		return !h.adapter.onebotWS.IsConnected()
+	})
+	waitUntil(t, "OneBot reconnected", func() bool {
+		return h.adapter.onebotWS.IsConnected() && h.onebot.ConnectionCount() == 1
+	})
+
+	h.pushGroupMessage(t, "Steve", "back again")
+	message, err := h.grunichat.WaitForNextMessage("chat", testTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.Body.ChatMessage != "back again" {
+		t.Errorf("chat message = %q, want %q", message.Body.ChatMessage, "back again")
+	}
+}
+
+func TestReconnectStopsAfterAuthRejection(t *testing.T) {
+	h := newTestHarness(t)
+	h.grunichat.RequireAuth(websocket.AuthToken, "secret")
+	h.config.GRUniChat.Auth.Mode = websocket.AuthToken
+	h.config.GRUniChat.Auth.Token = "secret"
+	h.start(t)
+
+	// 服务端更换令牌后断开，重连被拒绝
+	h.grunichat.RequireAuth(websocket.AuthToken, "rotated")
+	h.grunichat.DisconnectAll()
+	waitUntil(t, "rejected reconnect", func() bool {
+		return h.adapter.healthMonitor.Report().GRUniChat.Error != ""
+	})
+
+	report := h.adapter.healthMonitor.Report()
+	if report.Status != health.StatusDegraded || !strings.Contains(report.GRUniChat.Error, "authentication failed") {
+		t.Errorf("report = %+v, want degraded with the auth error", report)
+	}
+	rejected := len(h.grunichat.HelloErrors())
+	time.Sleep(2500 * time.Millisecond)
+	if got := len(h.grunichat.HelloErrors()); got != rejected {
+		t.Fatalf("kept reconnecting after the auth rejection: %d rejected hellos, want %d", got, rejected)
+	}
+
+	// 管理接口触发的重连仍然有效，成功后恢复自动重连并清除错误
+	h.grunichat.RequireAuth(websocket.AuthToken, "secret")
+	h.adapter.grunichatReconnect <- struct{}{}
+	waitUntil(t, "manual reconnect", func() bool {
+		report := h.adapter.healthMonitor.Report()
+		return report.GRUniChat.Connected && report.GRUniChat.Error == ""
+	})
+}
+
+func TestHelloRejectAfterConnectStopsReconnect(t *testing.T) {
+	h := startTestAdapter(t)
+	hellos := len(h.grunichat.Hellos())
+
+	if err := h.grunichat.SendRaw(&types.GRUniChatHandshake{Type: websocket.HandshakeReject, Reason: "client revoked"}); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, "auth error reported", func() bool {
+		return strings.Contains(h.adapter.healthMonitor.Report().GRUniChat.Error, "client revoked")
+	})
+
+	time.Sleep(1500 * time.Millisecond)
+	if h.adapter.grunichatWS.IsConnected() {
+		t.Error("reconnected after hello_reject")
+	}
+	if got := len(h.grunichat.Hellos()); got != hellos {
+		t.Errorf("hellos = %d, want %d (no reconnect after hello_reject)", got, hellos)
+	}
+}
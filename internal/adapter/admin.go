@@ -0,0 +1,244 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+
+	"grunichat-onebot-adapter/internal/httpserver"
+	"grunichat-onebot-adapter/internal/types"
+	"grunichat-onebot-adapter/internal/websocket"
+)
+
+// 注册管理接口，所有接口都需要 http.admin_token
+func (adapter *ModularAdapter) registerAdminRoutes(server *httpserver.Server) {
+	server.HandleAdmin("/admin/confirmations", http.HandlerFunc(adapter.handleAdminConfirmations))
+	server.HandleAdmin("/admin/blacklist", http.HandlerFunc(adapter.handleAdminBlacklist))
+	server.HandleAdmin("/admin/service-groups", http.HandlerFunc(adapter.handleAdminServiceGroups))
+	server.HandleAdmin("/admin/reconnect", http.HandlerFunc(adapter.handleAdminReconnect))
+	server.HandleAdmin("/admin/test-message", http.HandlerFunc(adapter.handleAdminTestMessage))
+	server.HandleAdmin("/admin/config", http.HandlerFunc(adapter.handleAdminConfig))
+}
+
+// 待确认命令：GET 列出，DELETE ?user_id=&group_id= 取消
+func (adapter *ModularAdapter) handleAdminConfirmations(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"pending": adapter.confirmationManager.ListPending(),
+		})
+	case http.MethodDelete:
+		userID, err := requireID(req, "user_id")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		groupID, err := requireID(req, "group_id")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if !adapter.confirmationManager.CancelPending(userID, groupID) {
+			writeError(w, http.StatusNotFound, fmt.Errorf("no pending command for user %d in group %d", userID, groupID))
+			return
+		}
+		adapter.logger.Infof("Admin cancelled pending command from user %d in group %d", userID, groupID)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"cancelled": true})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+	}
+}
+
+// 黑名单用户：GET 列出，POST/DELETE ?user_id= 添加或移除
+func (adapter *ModularAdapter) handleAdminBlacklist(w http.ResponseWriter, req *http.Request) {
+	filter := adapter.messageConverter.Filter()
+	adapter.handleAdminIDSet(w, req, "user_id", "users", idSet{
+		list:   filter.BlacklistUsers,
+		add:    filter.AddBlacklistUser,
+		remove: filter.RemoveBlacklistUser,
+		name:   "blacklist user",
+	})
+}
+
+// 服务群聊：GET 列出，POST/DELETE ?group_id= 添加或移除
+func (adapter *ModularAdapter) handleAdminServiceGroups(w http.ResponseWriter, req *http.Request) {
+	filter := adapter.messageConverter.Filter()
+	adapter.handleAdminIDSet(w, req, "group_id", "groups", idSet{
+		list:   filter.ServiceGroups,
+		add:    filter.AddServiceGroup,
+		remove: filter.RemoveServiceGroup,
+		name:   "service group",
+	})
+}
+
+// 可在运行时修改的ID集合
+type idSet struct {
+	list   func() []int64
+	add    func(id int64)
+	remove func(id int64) bool
+	name   string // 用于日志
+}
+
+// 处理ID集合的查询、添加和移除
+func (adapter *ModularAdapter) handleAdminIDSet(w http.ResponseWriter, req *http.Request, param, field string, set idSet) {
+	if req.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, map[string]interface{}{field: set.list()})
+		return
+	}
+
+	id, err := requireID(req, param)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	switch req.Method {
+	case http.MethodPost:
+		set.add(id)
+		adapter.logger.Infof("Admin added %s %d", set.name, id)
+	case http.MethodDelete:
+		if !set.remove(id) {
+			writeError(w, http.StatusNotFound, fmt.Errorf("%s %d not found", set.name, id))
+			return
+		}
+		adapter.logger.Infof("Admin removed %s %d", set.name, id)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{field: set.list()})
+}
+
+// 强制重连：POST ?target=onebot|grunichat
+func (adapter *ModularAdapter) handleAdminReconnect(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+		return
+	}
+
+	target := req.FormValue("target")
+	var ws websocket.IWebSocketManager
+	var trigger chan struct{}
+	switch target {
+	case "onebot":
+		ws, trigger = adapter.onebotWS, adapter.onebotReconnect
+	case "grunichat":
+		ws, trigger = adapter.grunichatWS, adapter.grunichatReconnect
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid target: %q (expected onebot or grunichat)", target))
+		return
+	}
+
+	adapter.logger.Infof("Admin requested reconnection of %s", target)
+	ws.Close()
+
+	// 通知重连监控立即重连
+	select {
+	case trigger <- struct{}{}:
+	default:
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{"reconnecting": target})
+}
+
+// 发送测试消息：POST ?group_id=&message= 发送到QQ群，或 ?client_id=&message= 发送到GRUniChat客户端
+func (adapter *ModularAdapter) handleAdminTestMessage(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+		return
+	}
+
+	message := req.FormValue("message")
+	if message == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("message is required"))
+		return
+	}
+
+	if clientID := req.FormValue("client_id"); clientID != "" {
+		gruniMsg := &types.GRUniChatMessage{
+			From:        adapter.config.GRUniChat.ClientID,
+			Type:        "chat",
+			TotalID:     uuid.New().String(),
+			CurrentTime: time.Now().Format("2006-01-02 15:04:05"),
+			Body: types.GRUniChatBody{
+				Sender:      "Admin",
+				ChatMessage: message,
+				ExecuteAt:   clientID,
+			},
+		}
+		if err := adapter.grunichatWS.SendMessage(gruniMsg); err != nil {
+			writeError(w, http.StatusServiceUnavailable, err)
+			return
+		}
+		adapter.logger.Infof("Admin sent test message to client %s", clientID)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"sent": true, "total_id": gruniMsg.TotalID})
+		return
+	}
+
+	groupID, err := requireID(req, "group_id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("group_id or client_id is required"))
+		return
+	}
+	if !adapter.onebotWS.IsConnected() {
+		writeError(w, http.StatusServiceUnavailable, fmt.Errorf("OneBot WebSocket not connected"))
+		return
+	}
+
+	adapter.onebotSender.SendGroupMessage(groupID, message)
+	adapter.logger.Infof("Admin sent test message to group %d", groupID)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"sent": true})
+}
+
+// 当前生效的配置（隐藏密钥，YAML格式）
+func (adapter *ModularAdapter) handleAdminConfig(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+		return
+	}
+
+	effective := adapter.config.Redacted()
+	filter := adapter.messageConverter.Filter()
+	effective.Filter.BlacklistUsers = filter.BlacklistUsers() // 包含运行时的修改
+	effective.Filter.ServiceGroups = filter.ServiceGroups()
+
+	data, err := yaml.Marshal(effective)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml; charset=utf-8")
+	w.Write(data)
+}
+
+// 读取必填的ID参数
+func requireID(req *http.Request, name string) (int64, error) {
+	raw := req.FormValue(name)
+	if raw == "" {
+		return 0, fmt.Errorf("%s is required", name)
+	}
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", name, raw)
+	}
+	return id, nil
+}
+
+// 输出JSON响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// 输出JSON错误
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]interface{}{"error": err.Error()})
+}
@@ -0,0 +1,222 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"grunichat-onebot-adapter/internal/config"
+	"grunichat-onebot-adapter/internal/sim/grunichatsim"
+	"grunichat-onebot-adapter/internal/websocket"
+)
+
+// 启用命令路由，不校验权限
+func enableCommands(cfg *config.Config) {
+	cfg.Command.EnableCommandRouting = true
+	cfg.Command.RequirePermission = false
+}
+
+func TestQQToGameGolden(t *testing.T) {
+	h := newTestHarness(t)
+	enableCommands(h.config)
+	h.start(t)
+
+	tests := []struct {
+		name        string
+		message     string
+		wantType    string
+		wantChat    string
+		wantCommand string
+		wantAt      string
+	}{
+		{"chat", "hello", "chat", "hello", "", "group_100"},
+		{"face and text", "[CQ:face,id=14]hi", "chat", "[微笑]hi", "", "group_100"},
+		{"targeted command", "!!command survival list", "command", "", "list", "survival"},
+		{"command without body", "!!command survival", "command", "", "", "survival"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h.grunichat.ResetMessages()
+			h.pushGroupMessage(t, "Steve", tt.message)
+
+			message, err := h.grunichat.WaitForNextMessage(tt.wantType, testTimeout)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body := message.Body
+			if body.Sender != "Steve" || body.ChatMessage != tt.wantChat || body.Command != tt.wantCommand || body.ExecuteAt != tt.wantAt {
+				t.Errorf("body = %+v, want chat %q command %q executeAt %q", body, tt.wantChat, tt.wantCommand, tt.wantAt)
+			}
+			if message.From != "QQ" {
+				t.Errorf("from = %q, want QQ", message.From)
+			}
+		})
+	}
+}
+
+func TestGameToQQGolden(t *testing.T) {
+	h := startTestAdapter(t)
+	// 方括号是字面文本，按CQ码转义后发送
+	from := "&#91;" + grunichatsim.DefaultServerID + "&#93;"
+
+	tests := []struct {
+		name string
+		send func() error
+		want string
+	}{
+		{"chat", func() error { return h.grunichat.SendChat("Alex", "hi", "group_100") }, "<" + from + " Alex> hi"},
+		{"formatted sender", func() error { return h.grunichat.SendChat("§aAlex§r", "hi", "group_100") }, "<" + from + " Alex> hi"},
+		{"event", func() error { return h.grunichat.SendEvent("", "Alex joined the game", "group_100") }, "<" + from + "> Alex joined the game"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h.onebot.ResetActions()
+			if err := tt.send(); err != nil {
+				t.Fatal(err)
+			}
+
+			action, err := h.onebot.WaitForNextAction("send_group_msg", testTimeout)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if action.GroupID() != testGroupID || action.Message() != tt.want {
+				t.Errorf("send_group_msg(%d, %q), want (%d, %q)", action.GroupID(), action.Message(), testGroupID, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandConfirmation(t *testing.T) {
+	h := newTestHarness(t)
+	enableCommands(h.config)
+	h.start(t)
+
+	h.pushGroupMessage(t, "Steve", "!!command i_confirm_all_client say hi")
+	prompt, err := h.onebot.WaitForNextAction("send_group_msg", testTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "@Steve 您要执行命令：say hi\n请回复 '确认' 或 '取消'"; prompt.Message() != want {
+		t.Errorf("prompt = %q, want %q", prompt.Message(), want)
+	}
+	if got := len(h.grunichat.MessagesOfType("command")); got != 0 {
+		t.Fatalf("command forwarded before confirmation: %d frame(s)", got)
+	}
+
+	h.pushGroupMessage(t, "Steve", "确认")
+	command, err := h.grunichat.WaitForNextMessage("command", testTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if command.Body.Command != "say hi" || command.Body.ExecuteAt != "" {
+		t.Errorf("command body = %+v, want broadcast of %q", command.Body, "say hi")
+	}
+	// 确认回复不作为聊天消息转发
+	if chats := h.grunichat.MessagesOfType("chat"); len(chats) != 0 {
+		t.Errorf("confirmation reply forwarded as chat: %+v", chats[0].Body)
+	}
+}
+
+func TestCommandCancellation(t *testing.T) {
+	h := newTestHarness(t)
+	enableCommands(h.config)
+	h.start(t)
+
+	h.pushGroupMessage(t, "Steve", "!!command i_confirm_all_client stop")
+	if _, err := h.onebot.WaitForNextAction("send_group_msg", testTimeout); err != nil {
+		t.Fatal(err)
+	}
+	h.pushGroupMessage(t, "Steve", "取消")
+	reply, err := h.onebot.WaitForNextAction("send_group_msg", testTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Message() != "命令已取消" {
+		t.Errorf("reply = %q, want 命令已取消", reply.Message())
+	}
+	if got := len(h.grunichat.MessagesOfType("command")); got != 0 {
+		t.Errorf("cancelled command forwarded: %d frame(s)", got)
+	}
+}
+
+func TestBroadcastToServiceGroups(t *testing.T) {
+	h := newTestHarness(t)
+	h.config.Filter.ServiceGroups = []int64{testGroupID, 200}
+	h.start(t)
+
+	if err := h.grunichat.SendChat("Alex", "hi all", ""); err != nil {
+		t.Fatal(err)
+	}
+	actions, err := h.onebot.WaitForActions("send_group_msg", 2, testTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups := []int64{actions[0].GroupID(), actions[1].GroupID()}
+	sort.Slice(groups, func(i, j int) bool { return groups[i] < groups[j] })
+	if groups[0] != testGroupID || groups[1] != 200 {
+		t.Errorf("broadcast groups = %v, want [%d 200]", groups, testGroupID)
+	}
+	for _, action := range actions {
+		if !strings.HasSuffix(action.Message(), "Alex> hi all") {
+			t.Errorf("group %d message = %q", action.GroupID(), action.Message())
+		}
+	}
+
+	// 指定群聊时只发送到该群
+	h.onebot.ResetActions()
+	if err := h.grunichat.SendChat("Alex", "only 200", "group_200"); err != nil {
+		t.Fatal(err)
+	}
+	action, err := h.onebot.WaitForNextAction("send_group_msg", testTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if action.GroupID() != 200 || len(h.onebot.ActionsNamed("send_group_msg")) != 1 {
+		t.Errorf("targeted chat sent to %+v", h.onebot.ActionsNamed("send_group_msg"))
+	}
+}
+
+func TestGRUniChatAuthModes(t *testing.T) {
+	for _, mode := range []string{websocket.AuthToken, websocket.AuthHMAC, websocket.AuthBearer} {
+		t.Run(mode, func(t *testing.T) {
+			h := newTestHarness(t)
+			h.grunichat.RequireAuth(mode, "secret")
+			h.config.GRUniChat.Auth.Mode = mode
+			h.config.GRUniChat.Auth.Token = "secret"
+			h.start(t)
+
+			if err := h.grunichat.SendChat("Alex", "authenticated", "group_100"); err != nil {
+				t.Fatal(err)
+			}
+			action, err := h.onebot.WaitForNextAction("send_group_msg", testTimeout)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasSuffix(action.Message(), "authenticated") {
+				t.Errorf("message = %q", action.Message())
+			}
+		})
+
+		t.Run(mode+" rejected", func(t *testing.T) {
+			h := newTestHarness(t)
+			h.grunichat.RequireAuth(mode, "secret")
+			h.config.GRUniChat.Auth.Mode = mode
+			h.config.GRUniChat.Auth.Token = "wrong"
+			adapter := NewModularAdapter(h.config, newTestLogger())
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := adapter.Start(ctx); !errors.Is(err, websocket.ErrAuthFailed) {
+				t.Fatalf("Start() error = %v, want ErrAuthFailed", err)
+			}
+			if len(h.grunichat.ClientIDs()) != 0 {
+				t.Errorf("rejected client registered as %v", h.grunichat.ClientIDs())
+			}
+		})
+	}
+}
@@ -2,13 +2,16 @@ package confirmation
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 
 	"grunichat-onebot-adapter/internal/formatter"
+	"grunichat-onebot-adapter/internal/i18n"
 	"grunichat-onebot-adapter/internal/sender"
 	"grunichat-onebot-adapter/internal/types"
 	"grunichat-onebot-adapter/internal/websocket"
@@ -20,10 +23,13 @@ type IConfirmationManager interface {
 	HandleConfirmationReply(onebot *types.OneBotMessage, message string) bool
 	CleanupExpiredCommands()
 	GetPendingCount() int
+	ListPending() []types.PendingCommand
+	CancelPending(userID, groupID int64) bool
 }
 
 // 命令确认管理器
 type CommandConfirmationManager struct {
+	mu              sync.Mutex
 	pendingCommands map[string]*types.PendingCommand // key: userID_groupID
 	formatter       *formatter.MessageFormatter
 	sender          sender.IMessageSender
@@ -53,6 +59,7 @@ func (ccm *CommandConfirmationManager) HandleConfirmationCommand(onebot *types.O
 	confirmKey := fmt.Sprintf("%d_%d", onebot.UserID, onebot.GroupID)
 
 	// 存储待确认的命令
+	ccm.mu.Lock()
 	ccm.pendingCommands[confirmKey] = &types.PendingCommand{
 		UserID:      onebot.UserID,
 		GroupID:     onebot.GroupID,
@@ -60,9 +67,10 @@ func (ccm *CommandConfirmationManager) HandleConfirmationCommand(onebot *types.O
 		OriginalMsg: originalMsg,
 		Timestamp:   time.Now().Unix(),
 	}
+	ccm.mu.Unlock()
 
 	// 发送确认消息到群里
-	confirmationMsg := ccm.formatter.FormatConfirmationMessage(senderName, command)
+	confirmationMsg := ccm.formatter.FormatConfirmationMessage(onebot, command)
 	ccm.sender.SendGroupMessage(onebot.GroupID, confirmationMsg)
 
 	ccm.logger.Debugf("Command pending confirmation from user %d in group %d: %s", onebot.UserID, onebot.GroupID, command)
@@ -72,33 +80,24 @@ func (ccm *CommandConfirmationManager) HandleConfirmationCommand(onebot *types.O
 func (ccm *CommandConfirmationManager) HandleConfirmationReply(onebot *types.OneBotMessage, message string) bool {
 	// 检查是否为确认回复
 	message = strings.TrimSpace(strings.ToLower(message))
-	if message != "yes" && message != "y" && message != "确认" && message != "是" {
+	if !ccm.matchesKeyword(onebot.GroupID, i18n.KeyConfirmationYesKeywords, message) {
 		// 检查取消命令
-		if message == "cancel" || message == "no" || message == "取消" {
-			confirmKey := fmt.Sprintf("%d_%d", onebot.UserID, onebot.GroupID)
-			if _, exists := ccm.pendingCommands[confirmKey]; exists {
-				delete(ccm.pendingCommands, confirmKey)
-				ccm.sender.SendGroupMessage(onebot.GroupID, "命令已取消")
-				return true
-			}
+		if ccm.matchesKeyword(onebot.GroupID, i18n.KeyConfirmationNoKeywords, message) {
+			return ccm.CancelPending(onebot.UserID, onebot.GroupID)
 		}
 		return false
 	}
 
-	// 生成确认键
-	confirmKey := fmt.Sprintf("%d_%d", onebot.UserID, onebot.GroupID)
-
-	// 查找待确认的命令
-	pending, exists := ccm.pendingCommands[confirmKey]
-	if !exists {
+	// 查找并取出待确认的命令
+	pending := ccm.takePending(onebot.UserID, onebot.GroupID)
+	if pending == nil {
 		return false
 	}
 
 	// 检查是否超时（5分钟）
 	if time.Now().Unix()-pending.Timestamp > 300 {
-		delete(ccm.pendingCommands, confirmKey)
 		ccm.logger.Debugf("Confirmation expired for user %d in group %d", onebot.UserID, onebot.GroupID)
-		ccm.sender.SendGroupMessage(onebot.GroupID, "命令确认已超时，请重新发送命令")
+		ccm.sender.SendGroupMessage(onebot.GroupID, ccm.formatter.Localize(onebot.GroupID, i18n.KeyConfirmationExpired, nil))
 		return false
 	}
 
@@ -109,14 +108,52 @@ func (ccm *CommandConfirmationManager) HandleConfirmationReply(onebot *types.One
 	ccm.executeConfirmedCommandToGRUniChat(pending)
 
 	// 发送确认消息到QQ群
-	ccm.sender.SendGroupMessage(onebot.GroupID, "命令已确认，正在广播到所有客户端")
+	ccm.sender.SendGroupMessage(onebot.GroupID, ccm.formatter.Localize(onebot.GroupID, i18n.KeyConfirmationConfirmed, nil))
+
+	return true
+}
+
+// 取出并删除待确认命令，不存在时返回nil
+func (ccm *CommandConfirmationManager) takePending(userID, groupID int64) *types.PendingCommand {
+	confirmKey := fmt.Sprintf("%d_%d", userID, groupID)
 
-	// 清理待确认命令
+	ccm.mu.Lock()
+	defer ccm.mu.Unlock()
+
+	pending, exists := ccm.pendingCommands[confirmKey]
+	if !exists {
+		return nil
+	}
 	delete(ccm.pendingCommands, confirmKey)
+	return pending
+}
 
+// 取消待确认命令并通知群，不存在时返回false
+func (ccm *CommandConfirmationManager) CancelPending(userID, groupID int64) bool {
+	if ccm.takePending(userID, groupID) == nil {
+		return false
+	}
+
+	ccm.logger.Debugf("Cancelled pending command from user %d in group %d", userID, groupID)
+	ccm.sender.SendGroupMessage(groupID, ccm.formatter.Localize(groupID, i18n.KeyConfirmationCancelled, nil))
 	return true
 }
 
+// 列出所有待确认命令，按提交时间排序
+func (ccm *CommandConfirmationManager) ListPending() []types.PendingCommand {
+	ccm.mu.Lock()
+	pending := make([]types.PendingCommand, 0, len(ccm.pendingCommands))
+	for _, command := range ccm.pendingCommands {
+		pending = append(pending, *command)
+	}
+	ccm.mu.Unlock()
+
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Timestamp < pending[j].Timestamp
+	})
+	return pending
+}
+
 // 执行已确认的命令，直接发送到GRUniChat广播
 func (ccm *CommandConfirmationManager) executeConfirmedCommandToGRUniChat(pending *types.PendingCommand) {
 	// 构建要广播的GRUniChat消息（不带executeAt字段）
@@ -127,7 +164,7 @@ func (ccm *CommandConfirmationManager) executeConfirmedCommandToGRUniChat(pendin
 		Type:        "command",
 		Body: types.GRUniChatBody{
 			Command: pending.Command,
-			Sender:  "QQ用户确认执行",
+			Sender:  ccm.formatter.Localize(pending.GroupID, i18n.KeyConfirmationSender, nil),
 			// 注意：不设置ExecuteAt，这样会广播到所有客户端
 		},
 	}
@@ -143,16 +180,36 @@ func (ccm *CommandConfirmationManager) executeConfirmedCommandToGRUniChat(pendin
 // 清理过期的待确认命令
 func (ccm *CommandConfirmationManager) CleanupExpiredCommands() {
 	now := time.Now().Unix()
+	var expired []*types.PendingCommand
+
+	ccm.mu.Lock()
 	for key, pending := range ccm.pendingCommands {
 		if now-pending.Timestamp > 300 { // 5分钟超时
 			delete(ccm.pendingCommands, key)
-			ccm.logger.Debugf("Cleaned up expired command from user %d in group %d", pending.UserID, pending.GroupID)
-			ccm.sender.SendGroupMessage(pending.GroupID, "命令确认已超时，请重新发送命令")
+			expired = append(expired, pending)
+		}
+	}
+	ccm.mu.Unlock()
+
+	for _, pending := range expired {
+		ccm.logger.Debugf("Cleaned up expired command from user %d in group %d", pending.UserID, pending.GroupID)
+		ccm.sender.SendGroupMessage(pending.GroupID, ccm.formatter.Localize(pending.GroupID, i18n.KeyConfirmationExpired, nil))
+	}
+}
+
+// 检查消息是否为群语言中的确认/取消关键词
+func (ccm *CommandConfirmationManager) matchesKeyword(groupID int64, key, message string) bool {
+	for _, keyword := range ccm.formatter.Catalog().Keywords(groupID, key) {
+		if message == keyword {
+			return true
 		}
 	}
+	return false
 }
 
 // 获取待确认命令数量
 func (ccm *CommandConfirmationManager) GetPendingCount() int {
+	ccm.mu.Lock()
+	defer ccm.mu.Unlock()
 	return len(ccm.pendingCommands)
 }
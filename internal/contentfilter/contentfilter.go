@@ -0,0 +1,257 @@
+package contentfilter
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"grunichat-onebot-adapter/internal/config"
+)
+
+// 消息方向
+const (
+	DirectionInbound  = "inbound"  // QQ → 游戏
+	DirectionOutbound = "outbound" // 游戏 → QQ
+	DirectionBoth     = "both"
+)
+
+// 规则动作
+const (
+	ActionDrop    = "drop"
+	ActionReplace = "replace"
+	ActionMask    = "mask"
+)
+
+// 命令执行结果过滤规则名称
+const CommandExecutionRule = "command_execution"
+
+// 编译后的过滤规则
+type rule struct {
+	name         string
+	direction    string
+	groups       map[int64]bool
+	messageTypes map[string]bool
+	pattern      *regexp.Regexp
+	action       string
+	replacement  string
+}
+
+// 过滤结果
+type Result struct {
+	Text    string // 处理后的文本
+	Dropped bool   // 是否被丢弃
+	Rule    string // 丢弃消息的规则名称
+}
+
+// 内容过滤流水线
+type Pipeline struct {
+	rules  []*rule
+	logger *logrus.Logger
+}
+
+// 根据配置创建内容过滤流水线，无效的规则会被跳过并记录日志
+func NewPipeline(cfg *config.Config, logger *logrus.Logger) *Pipeline {
+	pipeline := &Pipeline{logger: logger}
+
+	// 命令执行结果过滤作为内置规则
+	if cfg.Filter.FilterCommandExecutions && len(cfg.Filter.CommandExecutionKeywords) > 0 {
+		keywords := make([]string, 0, len(cfg.Filter.CommandExecutionKeywords))
+		for _, keyword := range cfg.Filter.CommandExecutionKeywords {
+			keywords = append(keywords, regexp.QuoteMeta(keyword))
+		}
+		pipeline.rules = append(pipeline.rules, &rule{
+			name:         CommandExecutionRule,
+			direction:    DirectionOutbound,
+			messageTypes: map[string]bool{"event": true},
+			pattern:      regexp.MustCompile("(?i)" + strings.Join(keywords, "|")),
+			action:       ActionDrop,
+		})
+	}
+
+	if !cfg.ContentFilter.Enabled {
+		return pipeline
+	}
+
+	for i, ruleCfg := range cfg.ContentFilter.Rules {
+		r, err := compileRule(ruleCfg)
+		if err != nil {
+			logger.Errorf("Invalid content filter rule #%d (%s): %v", i+1, ruleCfg.Name, err)
+			continue
+		}
+		pipeline.rules = append(pipeline.rules, r)
+	}
+
+	for _, listCfg := range cfg.ContentFilter.WordLists {
+		r, err := loadWordList(listCfg)
+		if err != nil {
+			logger.Errorf("Failed to load word list %s: %v", listCfg.Path, err)
+			continue
+		}
+		if r != nil {
+			pipeline.rules = append(pipeline.rules, r)
+		}
+	}
+
+	logger.Debugf("Content filter pipeline loaded with %d rules", len(pipeline.rules))
+	return pipeline
+}
+
+// 编译单条规则
+func compileRule(ruleCfg config.ContentRuleConfig) (*rule, error) {
+	pattern, err := regexp.Compile(ruleCfg.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	action := strings.ToLower(ruleCfg.Action)
+	switch action {
+	case ActionDrop, ActionReplace:
+	case ActionMask:
+		if ruleCfg.Replacement == "" {
+			ruleCfg.Replacement = "*"
+		}
+	default:
+		return nil, fmt.Errorf("unknown action %q", ruleCfg.Action)
+	}
+
+	direction, err := parseDirection(ruleCfg.Direction)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &rule{
+		name:        ruleCfg.Name,
+		direction:   direction,
+		groups:      toGroupSet(ruleCfg.Groups),
+		pattern:     pattern,
+		action:      action,
+		replacement: ruleCfg.Replacement,
+	}
+	if len(ruleCfg.MessageTypes) > 0 {
+		r.messageTypes = make(map[string]bool)
+		for _, messageType := range ruleCfg.MessageTypes {
+			r.messageTypes[messageType] = true
+		}
+	}
+	return r, nil
+}
+
+// 加载敏感词词表，编译为打码规则
+func loadWordList(listCfg config.WordListConfig) (*rule, error) {
+	file, err := os.Open(listCfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		words = append(words, word)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(words) == 0 {
+		return nil, nil
+	}
+
+	// 长词优先匹配
+	sort.Slice(words, func(i, j int) bool {
+		return len([]rune(words[i])) > len([]rune(words[j]))
+	})
+	for i, word := range words {
+		words[i] = regexp.QuoteMeta(word)
+	}
+
+	direction, err := parseDirection(listCfg.Direction)
+	if err != nil {
+		return nil, err
+	}
+
+	mask := listCfg.Mask
+	if mask == "" {
+		mask = "*"
+	}
+
+	return &rule{
+		name:        "word_list:" + listCfg.Path,
+		direction:   direction,
+		groups:      toGroupSet(listCfg.Groups),
+		pattern:     regexp.MustCompile("(?i)" + strings.Join(words, "|")),
+		action:      ActionMask,
+		replacement: mask,
+	}, nil
+}
+
+// 解析规则方向
+func parseDirection(direction string) (string, error) {
+	switch strings.ToLower(direction) {
+	case "", DirectionBoth:
+		return DirectionBoth, nil
+	case DirectionInbound:
+		return DirectionInbound, nil
+	case DirectionOutbound:
+		return DirectionOutbound, nil
+	default:
+		return "", fmt.Errorf("unknown direction %q", direction)
+	}
+}
+
+// 构建群聊集合
+func toGroupSet(groups []int64) map[int64]bool {
+	if len(groups) == 0 {
+		return nil
+	}
+	set := make(map[int64]bool, len(groups))
+	for _, groupID := range groups {
+		set[groupID] = true
+	}
+	return set
+}
+
+// 检查规则是否适用于该消息
+func (r *rule) applies(direction string, groupID int64, messageType string) bool {
+	if r.direction != DirectionBoth && r.direction != direction {
+		return false
+	}
+	if r.groups != nil && !r.groups[groupID] {
+		return false
+	}
+	if r.messageTypes != nil && !r.messageTypes[messageType] {
+		return false
+	}
+	return true
+}
+
+// 按顺序执行所有适用的规则
+func (p *Pipeline) Apply(direction string, groupID int64, messageType, text string) Result {
+	for _, r := range p.rules {
+		if !r.applies(direction, groupID, messageType) || !r.pattern.MatchString(text) {
+			continue
+		}
+
+		switch r.action {
+		case ActionDrop:
+			p.logger.Debugf("Message dropped by content filter rule %s: %s", r.name, text)
+			return Result{Text: text, Dropped: true, Rule: r.name}
+		case ActionReplace:
+			text = r.pattern.ReplaceAllString(text, r.replacement)
+		case ActionMask:
+			text = r.pattern.ReplaceAllStringFunc(text, func(match string) string {
+				return strings.Repeat(r.replacement, len([]rune(match)))
+			})
+		}
+	}
+
+	return Result{Text: text}
+}
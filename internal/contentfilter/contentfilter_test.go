@@ -0,0 +1,161 @@
+package contentfilter
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"grunichat-onebot-adapter/internal/config"
+)
+
+// 用给定规则创建流水线，日志写入 logs
+func newPipeline(logs io.Writer, rules ...config.ContentRuleConfig) *Pipeline {
+	cfg := config.Default()
+	cfg.Filter.FilterCommandExecutions = false
+	cfg.ContentFilter.Enabled = true
+	cfg.ContentFilter.Rules = rules
+
+	logger := logrus.New()
+	logger.SetOutput(logs)
+	return NewPipeline(cfg, logger)
+}
+
+func TestPipelineApply(t *testing.T) {
+	tests := []struct {
+		name        string
+		rule        config.ContentRuleConfig
+		direction   string
+		groupID     int64
+		messageType string
+		text        string
+		want        string
+		wantDropped bool
+	}{
+		{
+			name:      "drop",
+			rule:      config.ContentRuleConfig{Name: "ads", Pattern: `buy\s+now`, Action: ActionDrop},
+			direction: DirectionInbound, text: "buy  now!", want: "buy  now!", wantDropped: true,
+		},
+		{
+			name:      "mask keeps length",
+			rule:      config.ContentRuleConfig{Pattern: "bad", Action: ActionMask},
+			direction: DirectionInbound, text: "a bad word", want: "a *** word",
+		},
+		{
+			name:      "mask with custom character",
+			rule:      config.ContentRuleConfig{Pattern: "坏词", Action: ActionMask, Replacement: "#"},
+			direction: DirectionOutbound, text: "有坏词", want: "有##",
+		},
+		{
+			name:      "replace with capture group",
+			rule:      config.ContentRuleConfig{Pattern: `(\d{3})\d{4}(\d{4})`, Action: ActionReplace, Replacement: "$1****$2"},
+			direction: DirectionInbound, text: "call 13812345678", want: "call 138****5678",
+		},
+		{
+			name:      "action is case insensitive",
+			rule:      config.ContentRuleConfig{Pattern: "spam", Action: "DROP"},
+			direction: DirectionInbound, text: "spam", want: "spam", wantDropped: true,
+		},
+		{
+			name:      "inbound rule skips outbound",
+			rule:      config.ContentRuleConfig{Pattern: "bad", Action: ActionMask, Direction: DirectionInbound},
+			direction: DirectionOutbound, text: "bad", want: "bad",
+		},
+		{
+			name:      "outbound rule applies outbound",
+			rule:      config.ContentRuleConfig{Pattern: "bad", Action: ActionMask, Direction: DirectionOutbound},
+			direction: DirectionOutbound, text: "bad", want: "***",
+		},
+		{
+			name:      "both applies inbound",
+			rule:      config.ContentRuleConfig{Pattern: "bad", Action: ActionMask, Direction: DirectionBoth},
+			direction: DirectionInbound, text: "bad", want: "***",
+		},
+		{
+			name:      "group scope matches",
+			rule:      config.ContentRuleConfig{Pattern: "bad", Action: ActionMask, Groups: []int64{100}},
+			direction: DirectionInbound, groupID: 100, text: "bad", want: "***",
+		},
+		{
+			name:      "group scope skips other groups",
+			rule:      config.ContentRuleConfig{Pattern: "bad", Action: ActionMask, Groups: []int64{100}},
+			direction: DirectionInbound, groupID: 200, text: "bad", want: "bad",
+		},
+		{
+			name:      "message type scope",
+			rule:      config.ContentRuleConfig{Pattern: "joined", Action: ActionDrop, MessageTypes: []string{"event"}},
+			direction: DirectionOutbound, messageType: "chat", text: "Alex joined", want: "Alex joined",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := newPipeline(io.Discard, tt.rule)
+			result := pipeline.Apply(tt.direction, tt.groupID, tt.messageType, tt.text)
+			if result.Text != tt.want || result.Dropped != tt.wantDropped {
+				t.Errorf("Apply(%q) = %+v, want text %q dropped %v", tt.text, result, tt.want, tt.wantDropped)
+			}
+			if tt.wantDropped && result.Rule != tt.rule.Name {
+				t.Errorf("dropped by rule %q, want %q", result.Rule, tt.rule.Name)
+			}
+		})
+	}
+}
+
+func TestPipelineRulesRunInOrder(t *testing.T) {
+	pipeline := newPipeline(io.Discard,
+		config.ContentRuleConfig{Pattern: "cat", Action: ActionReplace, Replacement: "dog"},
+		config.ContentRuleConfig{Pattern: "dog", Action: ActionMask},
+	)
+	if result := pipeline.Apply(DirectionInbound, 1, "chat", "cat"); result.Text != "***" {
+		t.Errorf("Apply() = %q, want the replaced text masked", result.Text)
+	}
+}
+
+func TestNewPipelineSkipsInvalidRules(t *testing.T) {
+	var logs bytes.Buffer
+	pipeline := newPipeline(&logs,
+		config.ContentRuleConfig{Name: "broken", Pattern: "(unclosed", Action: ActionDrop},
+		config.ContentRuleConfig{Name: "bad action", Pattern: "x", Action: "explode"},
+		config.ContentRuleConfig{Name: "bad direction", Pattern: "x", Action: ActionDrop, Direction: "sideways"},
+		config.ContentRuleConfig{Name: "valid", Pattern: "bad", Action: ActionMask},
+	)
+
+	if len(pipeline.rules) != 1 || pipeline.rules[0].name != "valid" {
+		t.Fatalf("loaded %d rule(s), want only the valid rule", len(pipeline.rules))
+	}
+	if result := pipeline.Apply(DirectionInbound, 1, "chat", "bad"); result.Text != "***" {
+		t.Errorf("Apply() = %q, want the valid rule applied", result.Text)
+	}
+	for _, name := range []string{"#1 (broken)", "#2 (bad action)", "#3 (bad direction)"} {
+		if !strings.Contains(logs.String(), name) {
+			t.Errorf("no error logged for rule %s:\n%s", name, logs.String())
+		}
+	}
+}
+
+func TestWordListMasksLongestWordFirst(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.txt")
+	if err := os.WriteFile(path, []byte("# 注释\nfoo\n\nfoobar\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Default()
+	cfg.Filter.FilterCommandExecutions = false
+	cfg.ContentFilter.Enabled = true
+	cfg.ContentFilter.WordLists = []config.WordListConfig{{Path: path, Direction: DirectionInbound, Mask: "-"}}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	pipeline := NewPipeline(cfg, logger)
+
+	if result := pipeline.Apply(DirectionInbound, 1, "chat", "FOOBAR foo"); result.Text != "------ ---" {
+		t.Errorf("Apply() = %q, want every word masked", result.Text)
+	}
+	if result := pipeline.Apply(DirectionOutbound, 1, "chat", "foo"); result.Text != "foo" {
+		t.Errorf("outbound Apply() = %q, want the inbound word list skipped", result.Text)
+	}
+}
@@ -0,0 +1,194 @@
+package converter
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"grunichat-onebot-adapter/internal/i18n"
+	"grunichat-onebot-adapter/internal/ratelimit"
+	"grunichat-onebot-adapter/internal/types"
+)
+
+// 单个用户的防刷屏状态
+type userSpamState struct {
+	bucket          *ratelimit.TokenBucket
+	lastText        string
+	lastAt          time.Time
+	repeatCount     int
+	violations      int
+	lastViolationAt time.Time
+	mutedUntil      time.Time
+}
+
+// 单个群的防刷屏状态
+type groupSpamState struct {
+	bucket *ratelimit.TokenBucket
+}
+
+// 用户防刷屏状态的键，同一用户在不同群中分别计数
+type spamKey struct {
+	groupID int64
+	userID  int64
+}
+
+// 入站防刷屏状态
+type antiSpamState struct {
+	mu     sync.Mutex
+	users  map[spamKey]*userSpamState
+	groups map[int64]*groupSpamState // key: groupID
+}
+
+// 创建防刷屏状态
+func newAntiSpamState() *antiSpamState {
+	return &antiSpamState{
+		users:  make(map[spamKey]*userSpamState),
+		groups: make(map[int64]*groupSpamState),
+	}
+}
+
+// 检查消息是否触发防刷屏限制
+func (mf *MessageFilter) ShouldThrottle(onebot *types.OneBotMessage, text string) bool {
+	cfg := mf.config.AntiSpam
+	if !cfg.Enabled {
+		return false
+	}
+
+	// 授权用户不受限制
+	if mf.config.IsAuthorizedUser(onebot.UserID) {
+		return false
+	}
+
+	// 禁言通知在释放锁之后发送，避免发送器阻塞其他消息的检查
+	throttled, notice := mf.checkAntiSpam(onebot, text)
+	if notice != "" && mf.sender != nil {
+		mf.sender.SendGroupMessage(onebot.GroupID, notice)
+	}
+	return throttled
+}
+
+// 在锁内检查并更新防刷屏状态，返回是否过滤以及需要发送的禁言通知
+func (mf *MessageFilter) checkAntiSpam(onebot *types.OneBotMessage, text string) (bool, string) {
+	cfg := mf.config.AntiSpam
+
+	mf.antiSpam.mu.Lock()
+	defer mf.antiSpam.mu.Unlock()
+
+	now := time.Now()
+	key := spamKey{groupID: onebot.GroupID, userID: onebot.UserID}
+	state, exists := mf.antiSpam.users[key]
+	if !exists {
+		state = &userSpamState{
+			bucket: ratelimit.NewTokenBucket(cfg.UserPerMinute, cfg.UserBurst),
+		}
+		mf.antiSpam.users[key] = state
+	}
+
+	// 检查是否处于临时禁言中
+	if now.Before(state.mutedUntil) {
+		mf.logger.Debugf("User %d in group %d is temporarily muted until %s, filtering", onebot.UserID, onebot.GroupID, state.mutedUntil.Format("15:04:05"))
+		return true, ""
+	}
+
+	// 检查重复消息
+	repeatWindow := time.Duration(cfg.RepeatWindow) * time.Second
+	if text == state.lastText && now.Sub(state.lastAt) <= repeatWindow {
+		state.repeatCount++
+	} else {
+		state.repeatCount = 1
+	}
+	state.lastText = text
+	state.lastAt = now
+
+	if state.repeatCount >= cfg.RepeatThreshold {
+		mf.logger.Debugf("Repeated message from user %d in group %d (%d times), filtering", onebot.UserID, onebot.GroupID, state.repeatCount)
+		return true, mf.recordViolation(onebot, state, now)
+	}
+
+	// 检查用户发送频率
+	if !state.bucket.Allow() {
+		mf.logger.Debugf("User %d in group %d exceeded inbound rate limit, filtering", onebot.UserID, onebot.GroupID)
+		return true, mf.recordViolation(onebot, state, now)
+	}
+
+	// 检查群发送频率
+	group, exists := mf.antiSpam.groups[onebot.GroupID]
+	if !exists {
+		group = &groupSpamState{
+			bucket: ratelimit.NewTokenBucket(cfg.GroupPerMinute, cfg.GroupBurst),
+		}
+		mf.antiSpam.groups[onebot.GroupID] = group
+	}
+	if !group.bucket.Allow() {
+		mf.logger.Debugf("Group %d exceeded inbound rate limit, filtering", onebot.GroupID)
+		return true, ""
+	}
+
+	return false, ""
+}
+
+// 记录违规，达到阈值时临时禁言并返回需要发送的通知（调用方需持有锁）
+func (mf *MessageFilter) recordViolation(onebot *types.OneBotMessage, state *userSpamState, now time.Time) string {
+	cfg := mf.config.AntiSpam
+
+	if now.Sub(state.lastViolationAt) > time.Duration(cfg.ViolationWindow)*time.Second {
+		state.violations = 0
+	}
+	state.violations++
+	state.lastViolationAt = now
+
+	if state.violations < cfg.MuteThreshold {
+		return ""
+	}
+
+	duration := time.Duration(cfg.MuteDuration) * time.Second
+	state.mutedUntil = now.Add(duration)
+	state.violations = 0
+	mf.logger.Warnf("User %d in group %d temporarily muted for %v due to flooding", onebot.UserID, onebot.GroupID, duration)
+
+	if !cfg.NotifyGroup {
+		return ""
+	}
+	senderName := onebot.Sender.Nickname
+	if onebot.Sender.Card != "" {
+		senderName = onebot.Sender.Card
+	}
+	return mf.formatter.Localize(onebot.GroupID, i18n.KeyAntiSpamMuted, map[string]string{
+		"sender":  senderName,
+		"minutes": fmt.Sprintf("%d", (cfg.MuteDuration+59)/60),
+	})
+}
+
+// 截断超出最大长度的消息
+func (mf *MessageFilter) TruncateMessage(text string) string {
+	maxLength := mf.config.AntiSpam.MaxLength
+	if !mf.config.AntiSpam.Enabled || maxLength <= 0 {
+		return text
+	}
+
+	runes := []rune(text)
+	if len(runes) <= maxLength {
+		return text
+	}
+	return string(runes[:maxLength]) + "..."
+}
+
+// 清理已过期的防刷屏状态，令牌桶未回满的状态需要保留，否则短暂停顿后会重置为满桶
+func (mf *MessageFilter) CleanupAntiSpamState() {
+	mf.antiSpam.mu.Lock()
+	defer mf.antiSpam.mu.Unlock()
+
+	now := time.Now()
+	idle := time.Duration(mf.config.AntiSpam.ViolationWindow+mf.config.AntiSpam.RepeatWindow) * time.Second
+	for key, state := range mf.antiSpam.users {
+		if now.After(state.mutedUntil) && now.Sub(state.lastAt) > idle && now.Sub(state.lastViolationAt) > idle && state.bucket.Full() {
+			delete(mf.antiSpam.users, key)
+		}
+	}
+
+	for groupID, group := range mf.antiSpam.groups {
+		if group.bucket.Full() {
+			delete(mf.antiSpam.groups, groupID)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package converter
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"grunichat-onebot-adapter/internal/config"
+	"grunichat-onebot-adapter/internal/formatter"
+	"grunichat-onebot-adapter/internal/types"
+)
+
+// 在 release 关闭前阻塞所有发送的测试发送器
+type blockingSender struct {
+	entered chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (b *blockingSender) SendGroupMessage(groupID int64, message string) {
+	b.once.Do(func() { close(b.entered) })
+	<-b.release
+}
+
+func newAntiSpamFilter(t *testing.T, onebotSender *blockingSender) *MessageFilter {
+	t.Helper()
+	cfg := config.Default()
+	cfg.AntiSpam.Enabled = true
+	cfg.AntiSpam.RepeatThreshold = 100
+	cfg.AntiSpam.UserPerMinute = 1
+	cfg.AntiSpam.UserBurst = 1
+	cfg.AntiSpam.MuteThreshold = 1
+	cfg.AntiSpam.NotifyGroup = true
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewMessageFilter(cfg, logger, formatter.NewMessageFormatter(cfg, logger), onebotSender)
+}
+
+func groupMessage(groupID, userID int64, text string) *types.OneBotMessage {
+	return &types.OneBotMessage{
+		PostType:    "message",
+		MessageType: "group",
+		GroupID:     groupID,
+		UserID:      userID,
+		Sender:      types.OneBotSender{Nickname: "bob"},
+		Message:     text,
+	}
+}
+
+func TestShouldThrottleSendsMuteNoticeOutsideLock(t *testing.T) {
+	onebotSender := &blockingSender{entered: make(chan struct{}), release: make(chan struct{})}
+	mf := newAntiSpamFilter(t, onebotSender)
+	defer close(onebotSender.release)
+
+	if mf.ShouldThrottle(groupMessage(1, 5, "a"), "a") {
+		t.Fatal("first message should pass")
+	}
+	// 第二条消息超过用户速率，触发禁言通知，发送器会一直阻塞
+	go mf.ShouldThrottle(groupMessage(1, 5, "b"), "b")
+	select {
+	case <-onebotSender.entered:
+	case <-time.After(2 * time.Second):
+		t.Fatal("mute notice was not sent")
+	}
+
+	done := make(chan bool)
+	go func() { done <- mf.ShouldThrottle(groupMessage(1, 6, "c"), "c") }()
+	select {
+	case throttled := <-done:
+		if throttled {
+			t.Fatal("message from another user should pass")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("filter check blocked behind a slow sender")
+	}
+}
+
+func TestShouldThrottleTracksUsersPerGroup(t *testing.T) {
+	mf := newAntiSpamFilter(t, &blockingSender{entered: make(chan struct{}), release: make(chan struct{})})
+	mf.config.AntiSpam.NotifyGroup = false
+
+	if mf.ShouldThrottle(groupMessage(1, 5, "a"), "a") {
+		t.Fatal("first message should pass")
+	}
+	if !mf.ShouldThrottle(groupMessage(1, 5, "b"), "b") {
+		t.Fatal("second message in the same group should be throttled")
+	}
+	// 在另一个群中的发送频率单独计算，也不受第一个群的禁言影响
+	if mf.ShouldThrottle(groupMessage(2, 5, "c"), "c") {
+		t.Error("message in another group was throttled")
+	}
+}
+
+func TestCleanupAntiSpamStateEvictsRefilledState(t *testing.T) {
+	mf := newAntiSpamFilter(t, &blockingSender{entered: make(chan struct{}), release: make(chan struct{})})
+	mf.config.AntiSpam.NotifyGroup = false
+	mf.config.AntiSpam.RepeatWindow = 0
+	mf.config.AntiSpam.ViolationWindow = 0
+	// 每毫秒补充一个令牌，短暂空闲后即可回满
+	mf.config.AntiSpam.UserPerMinute = 60000
+	mf.config.AntiSpam.GroupPerMinute = 60000
+
+	mf.ShouldThrottle(groupMessage(1, 5, "a"), "a")
+	time.Sleep(20 * time.Millisecond)
+	mf.CleanupAntiSpamState()
+
+	mf.antiSpam.mu.Lock()
+	defer mf.antiSpam.mu.Unlock()
+	if len(mf.antiSpam.users) != 0 || len(mf.antiSpam.groups) != 0 {
+		t.Errorf("refilled state was not evicted: %d user(s), %d group(s)", len(mf.antiSpam.users), len(mf.antiSpam.groups))
+	}
+}
+
+func TestCleanupAntiSpamStateKeepsDrainedBuckets(t *testing.T) {
+	mf := newAntiSpamFilter(t, &blockingSender{entered: make(chan struct{}), release: make(chan struct{})})
+	mf.config.AntiSpam.NotifyGroup = false
+	mf.config.AntiSpam.RepeatWindow = 0
+	mf.config.AntiSpam.ViolationWindow = 0
+	mf.config.AntiSpam.GroupPerMinute = 1
+
+	mf.ShouldThrottle(groupMessage(1, 5, "a"), "a")
+	time.Sleep(20 * time.Millisecond)
+	mf.CleanupAntiSpamState()
+
+	// 空闲时间已超过检测窗口，但令牌桶尚未回满，删除后会重置为满桶
+	if !mf.ShouldThrottle(groupMessage(1, 5, "b"), "b") {
+		t.Error("user bucket was reset by cleanup")
+	}
+	mf.antiSpam.mu.Lock()
+	defer mf.antiSpam.mu.Unlock()
+	if _, exists := mf.antiSpam.groups[1]; !exists {
+		t.Error("drained group bucket was evicted")
+	}
+}
@@ -1,9 +1,12 @@
 package converter
 
 import (
+	"context"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,27 +14,38 @@ import (
 
 	"grunichat-onebot-adapter/internal/config"
 	"grunichat-onebot-adapter/internal/confirmation"
+	"grunichat-onebot-adapter/internal/contentfilter"
+	"grunichat-onebot-adapter/internal/emoji"
 	"grunichat-onebot-adapter/internal/formatter"
+	"grunichat-onebot-adapter/internal/history"
+	"grunichat-onebot-adapter/internal/i18n"
+	"grunichat-onebot-adapter/internal/media"
+	"grunichat-onebot-adapter/internal/metrics"
+	"grunichat-onebot-adapter/internal/middleware"
 	"grunichat-onebot-adapter/internal/sender"
 	"grunichat-onebot-adapter/internal/types"
 )
 
 // 消息转换器接口
 type IMessageConverter interface {
-	OneBotToGRUniChat(onebot *types.OneBotMessage) *types.GRUniChatMessage
-	GRUniChatToOneBot(gruni *types.GRUniChatMessage) *types.OneBotMessage
+	OneBotToGRUniChat(ctx context.Context, onebot *types.OneBotMessage) *types.GRUniChatMessage
+	GRUniChatToOneBot(ctx context.Context, gruni *types.GRUniChatMessage) *types.OneBotMessage
 }
 
 // 消息过滤器
 type MessageFilter struct {
 	config         *config.Config
 	logger         *logrus.Logger
+	sender         sender.IMessageSender // 用于发送防刷屏通知
+	formatter      *formatter.MessageFormatter
+	mu             sync.RWMutex   // 保护服务群聊和黑名单，二者可在运行时修改
 	serviceGroups  map[int64]bool // 提供服务的群聊
 	blacklistUsers map[int64]bool
+	antiSpam       *antiSpamState
 }
 
 // 创建消息过滤器
-func NewMessageFilter(cfg *config.Config, logger *logrus.Logger) *MessageFilter {
+func NewMessageFilter(cfg *config.Config, logger *logrus.Logger, fmt *formatter.MessageFormatter, onebotSender sender.IMessageSender) *MessageFilter {
 	// 构建服务群聊映射
 	serviceGroups := make(map[int64]bool)
 	for _, groupID := range cfg.Filter.ServiceGroups {
@@ -47,32 +61,110 @@ func NewMessageFilter(cfg *config.Config, logger *logrus.Logger) *MessageFilter
 	return &MessageFilter{
 		config:         cfg,
 		logger:         logger,
+		sender:         onebotSender,
+		formatter:      fmt,
 		serviceGroups:  serviceGroups,
 		blacklistUsers: blacklistUsers,
+		antiSpam:       newAntiSpamState(),
 	}
 }
 
+// 过滤原因
+const (
+	FilterReasonMessageType     = "message_type"      // 非群聊消息
+	FilterReasonBlacklist       = "blacklist"         // 黑名单用户
+	FilterReasonNonServiceGroup = "non_service_group" // 非服务群聊
+)
+
 // 检查消息是否应该被过滤
 func (mf *MessageFilter) ShouldFilter(onebot *types.OneBotMessage) bool {
+	return mf.FilterReason(onebot) != ""
+}
+
+// 获取消息被过滤的原因，不需要过滤时返回空字符串
+func (mf *MessageFilter) FilterReason(onebot *types.OneBotMessage) string {
+	mf.mu.RLock()
+	defer mf.mu.RUnlock()
+
 	// 只处理群聊消息，过滤掉私聊消息
 	if onebot.MessageType != "group" {
 		mf.logger.Debugf("Message type %s not supported, only group messages are allowed", onebot.MessageType)
-		return true
+		return FilterReasonMessageType
 	}
 
 	// 检查用户黑名单
 	if onebot.UserID != 0 && mf.blacklistUsers[onebot.UserID] {
 		mf.logger.Debugf("Message from blacklisted user %d, filtering", onebot.UserID)
-		return true
+		return FilterReasonBlacklist
 	}
 
 	// 检查是否为服务群聊（如果设置了服务群聊列表，只处理列表中的群聊）
 	if len(mf.serviceGroups) > 0 && onebot.GroupID != 0 && !mf.serviceGroups[onebot.GroupID] {
 		mf.logger.Debugf("Message from non-service group %d, filtering", onebot.GroupID)
-		return true
+		return FilterReasonNonServiceGroup
 	}
 
-	return false
+	return ""
+}
+
+// 添加黑名单用户（仅在运行期间生效）
+func (mf *MessageFilter) AddBlacklistUser(userID int64) {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+	mf.blacklistUsers[userID] = true
+}
+
+// 移除黑名单用户，用户不在黑名单中时返回false
+func (mf *MessageFilter) RemoveBlacklistUser(userID int64) bool {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+	if !mf.blacklistUsers[userID] {
+		return false
+	}
+	delete(mf.blacklistUsers, userID)
+	return true
+}
+
+// 获取黑名单用户列表
+func (mf *MessageFilter) BlacklistUsers() []int64 {
+	mf.mu.RLock()
+	defer mf.mu.RUnlock()
+	return sortedIDs(mf.blacklistUsers)
+}
+
+// 添加服务群聊（仅在运行期间生效）
+func (mf *MessageFilter) AddServiceGroup(groupID int64) {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+	mf.serviceGroups[groupID] = true
+}
+
+// 移除服务群聊，群聊不在列表中时返回false
+func (mf *MessageFilter) RemoveServiceGroup(groupID int64) bool {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+	if !mf.serviceGroups[groupID] {
+		return false
+	}
+	delete(mf.serviceGroups, groupID)
+	return true
+}
+
+// 获取服务群聊列表
+func (mf *MessageFilter) ServiceGroups() []int64 {
+	mf.mu.RLock()
+	defer mf.mu.RUnlock()
+	return sortedIDs(mf.serviceGroups)
+}
+
+// 将ID集合转换为有序列表
+func sortedIDs(set map[int64]bool) []int64 {
+	ids := make([]int64, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
 }
 
 // 消息转换器
@@ -82,7 +174,12 @@ type MessageConverter struct {
 	formatter           *formatter.MessageFormatter
 	confirmationManager confirmation.IConfirmationManager
 	onebotSender        sender.IMessageSender
+	media               *media.Relay   // 未启用媒体转发时为nil
+	history             *history.Store // 未启用历史记录时为nil
 	filter              *MessageFilter
+	contentFilter       *contentfilter.Pipeline
+	inboundChain        *middleware.Chain
+	outboundChain       *middleware.Chain
 }
 
 // 创建消息转换器
@@ -92,26 +189,64 @@ func NewMessageConverter(
 	fmt *formatter.MessageFormatter,
 	confirmationManager confirmation.IConfirmationManager,
 	onebotSender sender.IMessageSender,
+	mediaRelay *media.Relay,
+	messageHistory *history.Store,
 ) *MessageConverter {
-	return &MessageConverter{
+	mc := &MessageConverter{
 		config:              cfg,
 		logger:              logger,
 		formatter:           fmt,
 		confirmationManager: confirmationManager,
 		onebotSender:        onebotSender,
-		filter:              NewMessageFilter(cfg, logger),
+		media:               mediaRelay,
+		history:             messageHistory,
+		filter:              NewMessageFilter(cfg, logger, fmt, onebotSender),
+		contentFilter:       contentfilter.NewPipeline(cfg, logger),
 	}
+
+	// 注册内置阶段，外部注册的同名阶段会覆盖内置阶段
+	registry := middleware.NewRegistry()
+	mc.registerBuiltinStages(registry)
+	middleware.CopyRegistered(registry)
+
+	mc.inboundChain = mc.buildChain(registry, middleware.DirectionInbound, cfg.Middleware.Inbound)
+	mc.outboundChain = mc.buildChain(registry, middleware.DirectionOutbound, cfg.Middleware.Outbound)
+
+	return mc
 }
 
-// 将OneBot消息转换为GRUniChat消息
-func (mc *MessageConverter) OneBotToGRUniChat(onebot *types.OneBotMessage) *types.GRUniChatMessage {
-	if onebot.PostType != "message" {
-		return nil // 暂时只处理消息类型
+// 在配置的入站阶段之后追加中间件，需在处理消息前调用
+func (mc *MessageConverter) UseInbound(mw middleware.Middleware) {
+	mc.inboundChain.Use(mw)
+}
+
+// 在配置的出站阶段之后追加中间件，需在处理消息前调用
+func (mc *MessageConverter) UseOutbound(mw middleware.Middleware) {
+	mc.outboundChain.Use(mw)
+}
+
+// 按配置构建中间件链，未知阶段会被跳过并记录日志，缺少必需阶段时给出警告
+func (mc *MessageConverter) buildChain(registry *middleware.Registry, direction string, names []string) *middleware.Chain {
+	for _, required := range requiredStages[direction] {
+		if !hasStage(names, required) {
+			mc.logger.Warnf("middleware.%s does not include the %s stage, %s messages will bypass it", direction, required, direction)
+		}
 	}
 
-	// 过滤消息
-	if mc.filter.ShouldFilter(onebot) {
-		return nil
+	chain, err := registry.BuildWrapped(names, func(name string, mw middleware.Middleware) middleware.Middleware {
+		return timedStage(direction, name, mw)
+	})
+	if err != nil {
+		mc.logger.Errorf("Failed to build %s middleware chain: %v", direction, err)
+	}
+	mc.logger.Debugf("%s middleware chain: %v", direction, names)
+	return chain
+}
+
+// 将OneBot消息转换为GRUniChat消息，ctx 取消时中止处理阶段中的等待（如媒体下载）
+func (mc *MessageConverter) OneBotToGRUniChat(ctx context.Context, onebot *types.OneBotMessage) *types.GRUniChatMessage {
+	if onebot.PostType != "message" {
+		return nil // 暂时只处理消息类型
 	}
 
 	// 构建发送者名称
@@ -120,39 +255,51 @@ func (mc *MessageConverter) OneBotToGRUniChat(onebot *types.OneBotMessage) *type
 		senderName = onebot.Sender.Card
 	}
 
-	// 解析消息内容
-	rawMessage := mc.extractMessageText(onebot.Message)
-
-	// 检查是否为确认回复
-	if mc.confirmationManager.HandleConfirmationReply(onebot, rawMessage) {
-		return nil // 确认回复已处理，不需要转发
-	}
+	env := middleware.NewEnvelope(middleware.DirectionInbound)
+	env.OneBot = onebot
+	env.GroupID = onebot.GroupID
+	env.SenderName = senderName
+	env.Text = mc.extractMessageText(onebot.GroupID, onebot.Message) // 解析消息内容
 
 	// 构建基础消息结构
-	gruniMsg := &types.GRUniChatMessage{
+	env.GRUniChat = &types.GRUniChatMessage{
 		From:        mc.config.GRUniChat.ClientID, // 使用配置中的client_id
 		TotalID:     uuid.New().String(),
 		CurrentTime: time.Now().Format("2006-01-02 15:04:05"), // 使用正确的时间格式
 		Body: types.GRUniChatBody{
-			Sender: senderName, // 发送者昵称
+			Sender: mc.formatter.ColorizeSender(senderName, onebot.Sender.Role), // 发送者昵称
 		},
 	}
 
-	// 检查是否为命令 (!!command 格式)
-	if strings.HasPrefix(rawMessage, "!!command ") {
-		return mc.handleCommand(onebot, senderName, rawMessage, gruniMsg)
+	var gruniMsg *types.GRUniChatMessage
+	handler := mc.inboundChain.Then(func(ctx context.Context, env *middleware.Envelope) error {
+		// 未被任何阶段处理的消息作为普通聊天消息转发
+		if env.GRUniChat.Type == "" {
+			mc.buildChatMessage(env, env.Text)
+		}
+		gruniMsg = env.GRUniChat
+		mc.recordInbound(env)
+		metrics.MessagesTotal.Inc(middleware.DirectionInbound, strconv.FormatInt(env.GroupID, 10), env.GRUniChat.From)
+		return nil
+	})
+
+	if err := handler(ctx, env); err != nil {
+		mc.logger.Errorf("Inbound middleware chain failed: %v", err)
+		return nil
 	}
 
-	// 普通聊天消息
-	gruniMsg.Type = "chat"
-	gruniMsg.Body.ChatMessage = mc.formatter.FormatOneBotGroupMessage(rawMessage)
+	return gruniMsg // 为nil表示消息被过滤或已处理（如确认命令）
+}
+
+// 构建普通聊天消息
+func (mc *MessageConverter) buildChatMessage(env *middleware.Envelope, chatMessage string) {
+	env.GRUniChat.Type = "chat"
+	env.GRUniChat.Body.ChatMessage = chatMessage
 
 	// 设置群组路由信息（如果是群消息）
-	if onebot.MessageType == "group" && onebot.GroupID != 0 {
-		gruniMsg.Body.ExecuteAt = fmt.Sprintf("group_%d", onebot.GroupID)
+	if env.OneBot.MessageType == "group" && env.OneBot.GroupID != 0 {
+		env.GRUniChat.Body.ExecuteAt = fmt.Sprintf("group_%d", env.OneBot.GroupID)
 	}
-
-	return gruniMsg
 }
 
 // 处理命令消息
@@ -197,14 +344,14 @@ func (mc *MessageConverter) handleCommand(onebot *types.OneBotMessage, senderNam
 	} else {
 		// 格式不正确，当作普通消息处理
 		gruniMsg.Type = "chat"
-		gruniMsg.Body.ChatMessage = mc.formatter.FormatOneBotGroupMessage(rawMessage)
+		gruniMsg.Body.ChatMessage = mc.formatter.FormatOneBotGroupMessage(onebot, rawMessage)
 	}
 
 	return gruniMsg
 }
 
 // 将GRUniChat消息转换为OneBot消息（用于发送到OneBot）
-func (mc *MessageConverter) GRUniChatToOneBot(gruni *types.GRUniChatMessage) *types.OneBotMessage {
+func (mc *MessageConverter) GRUniChatToOneBot(ctx context.Context, gruni *types.GRUniChatMessage) *types.OneBotMessage {
 	mc.logger.Debugf("Converting GRUniChat message to OneBot: %s from %s", gruni.Body.ChatMessage, gruni.Body.Sender)
 
 	// 处理聊天类型和事件类型的消息
@@ -216,7 +363,7 @@ func (mc *MessageConverter) GRUniChatToOneBot(gruni *types.GRUniChatMessage) *ty
 	// 检查是否有ExecuteAt路由信息
 	if gruni.Body.ExecuteAt == "" {
 		mc.logger.Debug("No executeAt specified, broadcasting to all service groups")
-		mc.broadcastToServiceGroups(gruni)
+		mc.broadcastToServiceGroups(ctx, gruni)
 		return nil
 	}
 
@@ -224,7 +371,7 @@ func (mc *MessageConverter) GRUniChatToOneBot(gruni *types.GRUniChatMessage) *ty
 	if strings.HasPrefix(gruni.Body.ExecuteAt, "group_") {
 		groupIDStr := strings.TrimPrefix(gruni.Body.ExecuteAt, "group_")
 		if groupID, err := strconv.ParseInt(groupIDStr, 10, 64); err == nil {
-			mc.sendToSpecificGroup(gruni, groupID)
+			mc.sendToSpecificGroup(ctx, gruni, groupID)
 		} else {
 			mc.logger.Errorf("Invalid group ID in executeAt: %s", gruni.Body.ExecuteAt)
 		}
@@ -236,70 +383,88 @@ func (mc *MessageConverter) GRUniChatToOneBot(gruni *types.GRUniChatMessage) *ty
 }
 
 // 广播消息到所有服务群组
-func (mc *MessageConverter) broadcastToServiceGroups(gruni *types.GRUniChatMessage) {
-	for groupID := range mc.getServiceGroups() {
-		mc.sendToSpecificGroup(gruni, groupID)
+func (mc *MessageConverter) broadcastToServiceGroups(ctx context.Context, gruni *types.GRUniChatMessage) {
+	for _, groupID := range mc.filter.ServiceGroups() {
+		mc.sendToSpecificGroup(ctx, gruni, groupID)
 	}
 }
 
 // 发送消息到指定群组
-func (mc *MessageConverter) sendToSpecificGroup(gruni *types.GRUniChatMessage, groupID int64) {
-	// 检查是否需要过滤命令执行结果消息
-	if mc.config.Filter.FilterCommandExecutions && mc.isCommandExecutionMessage(gruni) {
-		mc.logger.Debugf("Filtered command execution message from %s: %s", gruni.From, gruni.Body.EventDetail)
-		return
+func (mc *MessageConverter) sendToSpecificGroup(ctx context.Context, gruni *types.GRUniChatMessage, groupID int64) {
+	env := middleware.NewEnvelope(middleware.DirectionOutbound)
+	env.GRUniChat = gruni
+	env.GroupID = groupID
+	env.Text = gruni.Body.ChatMessage
+	if gruni.Type == "event" {
+		env.Text = gruni.Body.EventDetail
 	}
 
-	var message string
+	handler := mc.outboundChain.Then(func(ctx context.Context, env *middleware.Envelope) error {
+		// 游戏文本一律按CQ码转义后发送，启用表情转换时短代码转换为QQ表情
+		// env.Text 保持转义前的文本，历史记录中保存的是纯文本
+		message := emoji.EscapeCQText(env.Text)
+		if mc.config.Emoji.TranslateFaces {
+			message = emoji.ShortcodesToCQFaces(env.Text)
+		}
 
-	// 根据消息类型格式化内容
-	if gruni.Type == "event" {
-		// 事件消息格式：<[客户端]> 事件详情
-		message = mc.formatter.FormatEventMessageForOneBot(gruni.From, gruni.Body.EventDetail)
-	} else {
-		// 聊天消息格式：<[客户端] 用户名> 消息内容
-		message = mc.formatter.FormatChatMessageForOneBot(gruni.From, gruni.Body.Sender, gruni.Body.ChatMessage)
+		// 发送消息
+		mc.onebotSender.SendGroupMessage(env.GroupID, message)
+		mc.logger.Debugf("Sent message to group %d: %s", env.GroupID, message)
+		mc.recordOutbound(env)
+		metrics.MessagesTotal.Inc(middleware.DirectionOutbound, strconv.FormatInt(env.GroupID, 10), env.GRUniChat.From)
+		return nil
+	})
+
+	if err := handler(ctx, env); err != nil {
+		mc.logger.Errorf("Outbound middleware chain failed for group %d: %v", groupID, err)
 	}
+}
 
-	// 发送消息
-	mc.onebotSender.SendGroupMessage(groupID, message)
-	mc.logger.Debugf("Sent message to group %d: %s", groupID, message)
+// 清理过期的过滤器状态
+func (mc *MessageConverter) CleanupExpiredState() {
+	mc.filter.CleanupAntiSpamState()
 }
 
-// 获取服务群组列表
-func (mc *MessageConverter) getServiceGroups() map[int64]bool {
-	serviceGroups := make(map[int64]bool)
-	for _, groupID := range mc.config.Filter.ServiceGroups {
-		serviceGroups[groupID] = true
-	}
-	return serviceGroups
+// 获取消息过滤器，用于在运行时修改服务群聊和黑名单
+func (mc *MessageConverter) Filter() *MessageFilter {
+	return mc.filter
 }
 
 // 从OneBot消息中提取文本内容（处理string和数组两种格式）
-func (mc *MessageConverter) extractMessageText(message interface{}) string {
+func (mc *MessageConverter) extractMessageText(groupID int64, message interface{}) string {
 	switch msg := message.(type) {
 	case string:
-		// 如果是字符串直接返回
-		return msg
+		// 如果是字符串，转换其中的表情和媒体CQ码
+		if mc.config.Emoji.TranslateFaces {
+			msg = emoji.CQFacesToText(msg)
+		}
+		if mc.media != nil {
+			msg = mc.replaceCQMedia(groupID, msg)
+		}
+		return mc.translateEmoji(msg)
 	case []interface{}:
-		// 如果是数组，遍历提取text类型的消息段
+		// 如果是数组，遍历提取text和face类型的消息段
 		var textParts []string
 		for _, segment := range msg {
 			if segmentMap, ok := segment.(map[string]interface{}); ok {
-				if msgType, exists := segmentMap["type"]; exists && msgType == "text" {
-					if data, dataExists := segmentMap["data"]; dataExists {
-						if dataMap, dataOk := data.(map[string]interface{}); dataOk {
-							if text, textExists := dataMap["text"]; textExists {
-								if textStr, textOk := text.(string); textOk {
-									textParts = append(textParts, textStr)
-								}
-							}
-						}
+				dataMap, _ := segmentMap["data"].(map[string]interface{})
+				switch segmentMap["type"] {
+				case "text":
+					if textStr, textOk := dataMap["text"].(string); textOk {
+						textParts = append(textParts, textStr)
+					}
+				case "face":
+					if mc.config.Emoji.TranslateFaces {
+						textParts = append(textParts, emoji.FaceText(segmentFaceID(dataMap)))
+					}
+				case "image", "video", "file":
+					if mc.media != nil {
+						textParts = append(textParts, mc.mediaPlaceholder(groupID, segmentMap["type"].(string)))
 					}
 				}
 			}
 		}
-		return strings.Join(textParts, "")
+		return mc.translateEmoji(strings.Join(textParts, ""))
 	default:
 		// 未知格式，记录日志并返回空字符串
 		mc.logger.Warnf("Unknown message format: %T", message)
@@ -307,39 +472,38 @@ func (mc *MessageConverter) extractMessageText(message interface{}) string {
 	}
 }
 
+// 将Unicode emoji转换为文字短代码
+func (mc *MessageConverter) translateEmoji(text string) string {
+	if !mc.config.Emoji.TranslateEmoji {
+		return text
+	}
+	return emoji.EmojiToShortcodes(text)
+}
+
+// 获取face消息段的表情ID（可能是字符串或数字）
+func segmentFaceID(data map[string]interface{}) int {
+	switch id := data["id"].(type) {
+	case string:
+		if value, err := strconv.Atoi(id); err == nil {
+			return value
+		}
+	case float64:
+		return int(id)
+	}
+	return -1
+}
+
 // 发送权限不足的回复消息
 func (mc *MessageConverter) sendPermissionDeniedReply(onebot *types.OneBotMessage) {
 	// 只在群聊中回复权限不足消息
 	if onebot.MessageType == "group" {
-		mc.onebotSender.SendGroupMessage(onebot.GroupID, mc.config.Command.PermissionDeniedMsg)
+		message := mc.config.Command.PermissionDeniedMsg
+		if message == "" {
+			message = mc.formatter.Localize(onebot.GroupID, i18n.KeyPermissionDenied, nil)
+		}
+		mc.onebotSender.SendGroupMessage(onebot.GroupID, message)
 		mc.logger.Debugf("Sent permission denied reply to user %d in group %d", onebot.UserID, onebot.GroupID)
 	} else {
 		mc.logger.Warnf("Permission denied reply only supported for group messages, ignoring %s message", onebot.MessageType)
 	}
 }
-
-// 检测是否为命令执行结果消息
-func (mc *MessageConverter) isCommandExecutionMessage(gruni *types.GRUniChatMessage) bool {
-	// 只检查事件类型的消息
-	if gruni.Type != "event" {
-		return false
-	}
-
-	eventDetail := strings.ToLower(gruni.Body.EventDetail)
-
-	// 检查是否包含命令执行相关的关键词
-	commandKeywords := []string{
-		"executed command",  // 执行命令
-		"player executed",   // 玩家执行
-		"changed the block", // 更改方块
-		"command ->",        // 命令箭头
-	}
-
-	for _, keyword := range commandKeywords {
-		if strings.Contains(eventDetail, keyword) {
-			return true
-		}
-	}
-
-	return false
-}
@@ -0,0 +1,149 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"grunichat-onebot-adapter/internal/config"
+	"grunichat-onebot-adapter/internal/confirmation"
+	"grunichat-onebot-adapter/internal/formatter"
+	"grunichat-onebot-adapter/internal/types"
+)
+
+// 记录发出消息的测试发送器
+type recordingSender struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (r *recordingSender) SendGroupMessage(groupID int64, message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, message)
+}
+
+func (r *recordingSender) sent() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.messages...)
+}
+
+// 只服务群100、原样输出游戏消息文本的配置
+func converterConfig() *config.Config {
+	cfg := config.Default()
+	cfg.Filter.ServiceGroups = []int64{100}
+	cfg.Format.ChatMessageFormat = "{message}"
+	cfg.Emoji.TranslateFaces = false
+	return cfg
+}
+
+func newTestConverter(t *testing.T, cfg *config.Config) (*MessageConverter, *recordingSender) {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	onebotSender := &recordingSender{}
+	fmt := formatter.NewMessageFormatter(cfg, logger)
+	confirmationManager := confirmation.NewCommandConfirmationManager(fmt, onebotSender, nil, logger)
+	return NewMessageConverter(cfg, logger, fmt, confirmationManager, onebotSender, nil, nil), onebotSender
+}
+
+func gameChat(text string) *types.GRUniChatMessage {
+	return &types.GRUniChatMessage{
+		From: "survival",
+		Type: "chat",
+		Body: types.GRUniChatBody{Sender: "Steve", ChatMessage: text},
+	}
+}
+
+func TestMinecraftStageConvertsOutboundText(t *testing.T) {
+	mc, onebotSender := newTestConverter(t, converterConfig())
+
+	mc.GRUniChatToOneBot(context.Background(), gameChat(`§aHello §r["",{"text":"x"}]`))
+	mc.GRUniChatToOneBot(context.Background(), gameChat(`{"text":"hi","extra":[{"text":"!"}]}`))
+	mc.GRUniChatToOneBot(context.Background(), gameChat(`[1,2]`))
+
+	got := onebotSender.sent()
+	// 发送前按CQ码转义，方括号变为 &#91; 和 &#93;
+	want := []string{`Hello &#91;"",{"text":"x"}&#93;`, "hi!", "&#91;1,2&#93;"}
+	if len(got) != len(want) {
+		t.Fatalf("sent %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("message %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestMinecraftStageCanBeRemoved(t *testing.T) {
+	cfg := converterConfig()
+	cfg.Middleware.Outbound = []string{StageContentFilter, StageFormat}
+	mc, onebotSender := newTestConverter(t, cfg)
+
+	mc.GRUniChatToOneBot(context.Background(), gameChat(`§aHello`))
+
+	if got := onebotSender.sent(); len(got) != 1 || got[0] != "§aHello" {
+		t.Fatalf("sent %q, want the raw text when the minecraft stage is removed", got)
+	}
+}
+
+func TestOutboundEscapesCQCodes(t *testing.T) {
+	tests := []struct {
+		name           string
+		translateFaces bool
+		text           string
+		want           string
+	}{
+		{"without face translation", false, "[doge] [CQ:at,qq=all] &", "&#91;doge&#93; &#91;CQ:at,qq=all&#93; &amp;"},
+		{"with face translation", true, "[doge] [CQ:at,qq=all]", "[CQ:face,id=179] &#91;CQ:at,qq=all&#93;"},
+		{"cq code only", true, "[CQ:at,qq=all]", "&#91;CQ:at,qq=all&#93;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := converterConfig()
+			cfg.Emoji.TranslateFaces = tt.translateFaces
+			mc, onebotSender := newTestConverter(t, cfg)
+
+			mc.GRUniChatToOneBot(context.Background(), gameChat(tt.text))
+			if got := onebotSender.sent(); len(got) != 1 || got[0] != tt.want {
+				t.Fatalf("sent %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMissingRequiredStagesLogWarnings(t *testing.T) {
+	cfg := converterConfig()
+	cfg.Middleware.Inbound = []string{StageConfirmation, StageFormat}
+	cfg.Middleware.Outbound = []string{StageFormat}
+
+	var logs bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&logs)
+	fmt := formatter.NewMessageFormatter(cfg, logger)
+	NewMessageConverter(cfg, logger, fmt, confirmation.NewCommandConfirmationManager(fmt, &recordingSender{}, nil, logger), &recordingSender{}, nil, nil)
+
+	for _, want := range []string{
+		"middleware.inbound does not include the filter stage",
+		"middleware.inbound does not include the content_filter stage",
+		"middleware.outbound does not include the content_filter stage",
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("missing warning %q in:\n%s", want, logs.String())
+		}
+	}
+
+	// 默认配置包含所有必需阶段
+	logs.Reset()
+	cfg = converterConfig()
+	NewMessageConverter(cfg, logger, fmt, nil, &recordingSender{}, nil, nil)
+	if strings.Contains(logs.String(), "does not include") {
+		t.Errorf("unexpected warning with the default stages:\n%s", logs.String())
+	}
+}
@@ -0,0 +1,164 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"grunichat-onebot-adapter/internal/emoji"
+	"grunichat-onebot-adapter/internal/history"
+	"grunichat-onebot-adapter/internal/i18n"
+	"grunichat-onebot-adapter/internal/middleware"
+	"grunichat-onebot-adapter/internal/types"
+)
+
+// 检查文本是否为历史查询命令
+func isHistoryCommand(text string) bool {
+	return text == "!!history" || strings.HasPrefix(text, "!!history ")
+}
+
+// 记录一条消息，未启用历史记录时不做任何操作
+func (mc *MessageConverter) recordHistory(record *history.Record) {
+	if mc.history == nil {
+		return
+	}
+	if err := mc.history.Append(record); err != nil {
+		mc.logger.Errorf("Failed to record %s message: %v", record.Direction, err)
+	}
+}
+
+// 记录转发到GRUniChat的消息
+func (mc *MessageConverter) recordInbound(env *middleware.Envelope) {
+	record := &history.Record{
+		Direction: history.DirectionInbound,
+		Type:      env.GRUniChat.Type,
+		GroupID:   env.OneBot.GroupID,
+		UserID:    env.OneBot.UserID,
+		Sender:    env.SenderName,
+		ClientID:  env.GRUniChat.From,
+		TotalID:   env.GRUniChat.TotalID,
+		MessageID: env.OneBot.MessageID,
+		Text:      env.Text,
+	}
+	if env.OneBot.Time != 0 {
+		sourceTime := time.Unix(env.OneBot.Time, 0)
+		record.SourceTime = &sourceTime
+	}
+	mc.recordHistory(record)
+}
+
+// 记录发送到QQ群的消息
+func (mc *MessageConverter) recordOutbound(env *middleware.Envelope) {
+	record := &history.Record{
+		Direction: history.DirectionOutbound,
+		Type:      env.GRUniChat.Type,
+		GroupID:   env.GroupID,
+		Sender:    mc.formatter.MinecraftToPlainText(env.GRUniChat.Body.Sender),
+		ClientID:  env.GRUniChat.From,
+		TotalID:   env.GRUniChat.TotalID,
+		Text:      env.Text, // 格式化后、CQ码转义前的文本
+	}
+	if sourceTime, err := time.ParseInLocation("2006-01-02 15:04:05", env.GRUniChat.CurrentTime, time.Local); err == nil {
+		record.SourceTime = &sourceTime
+	}
+	mc.recordHistory(record)
+}
+
+// 处理 !!history 查询命令（仅入站）
+//
+// 格式：!!history [user=QQ号] [since=30m|2h|1d] [关键词]
+func (mc *MessageConverter) historyStage(ctx context.Context, env *middleware.Envelope, next middleware.Handler) error {
+	if env.Direction != middleware.DirectionInbound || mc.history == nil || !isHistoryCommand(env.Text) {
+		return next(ctx, env)
+	}
+
+	if !mc.config.HasCommandPermission(env.OneBot.UserID) {
+		mc.logger.Warnf("User %d attempted to query history without permission", env.OneBot.UserID)
+		mc.sendPermissionDeniedReply(env.OneBot)
+		return nil
+	}
+
+	query := parseHistoryArgs(strings.Fields(strings.TrimPrefix(env.Text, "!!history")))
+	query.GroupID = env.OneBot.GroupID
+	query.Limit = mc.config.History.CommandLimit
+
+	records, err := mc.history.Query(query)
+	if err != nil {
+		mc.logger.Errorf("History query failed: %v", err)
+		return nil
+	}
+
+	mc.onebotSender.SendGroupMessage(env.OneBot.GroupID, mc.formatHistoryReply(env.OneBot, records))
+	return nil // 查询命令不转发
+}
+
+// 解析历史查询参数，无法识别的参数作为关键词
+func parseHistoryArgs(args []string) history.Query {
+	var query history.Query
+	var keywords []string
+
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "user="):
+			if userID, err := strconv.ParseInt(strings.TrimPrefix(arg, "user="), 10, 64); err == nil {
+				query.UserID = userID
+				continue
+			}
+		case strings.HasPrefix(arg, "since="):
+			if duration, ok := parseHistoryDuration(strings.TrimPrefix(arg, "since=")); ok {
+				query.Since = time.Now().Add(-duration)
+				continue
+			}
+		}
+		keywords = append(keywords, arg)
+	}
+
+	query.Text = strings.Join(keywords, " ")
+	return query
+}
+
+// 解析时间范围，支持 m（分钟）、h（小时）、d（天）
+func parseHistoryDuration(value string) (time.Duration, bool) {
+	if len(value) < 2 {
+		return 0, false
+	}
+
+	amount, err := strconv.Atoi(value[:len(value)-1])
+	if err != nil || amount <= 0 {
+		return 0, false
+	}
+
+	switch value[len(value)-1] {
+	case 'm':
+		return time.Duration(amount) * time.Minute, true
+	case 'h':
+		return time.Duration(amount) * time.Hour, true
+	case 'd':
+		return time.Duration(amount) * 24 * time.Hour, true
+	}
+	return 0, false
+}
+
+// 格式化历史查询结果，按时间从旧到新显示
+// 记录中的文本和发送者都是纯文本，按CQ码转义后拼接，避免记录的 [CQ:...] 在查询时变成真正的CQ码
+func (mc *MessageConverter) formatHistoryReply(onebot *types.OneBotMessage, records []history.Record) string {
+	if len(records) == 0 {
+		return mc.formatter.Localize(onebot.GroupID, i18n.KeyHistoryEmpty, nil)
+	}
+
+	lines := []string{mc.formatter.Localize(onebot.GroupID, i18n.KeyHistoryHeader, map[string]string{
+		"count": fmt.Sprintf("%d", len(records)),
+	})}
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		if record.Direction == history.DirectionOutbound {
+			// 出站记录的文本已经按格式模板包含了发送者
+			lines = append(lines, fmt.Sprintf("[%s] %s", record.Time.Format("01-02 15:04"), emoji.EscapeCQText(record.Text)))
+		} else {
+			lines = append(lines, fmt.Sprintf("[%s] %s: %s", record.Time.Format("01-02 15:04"), emoji.EscapeCQText(record.Sender), emoji.EscapeCQText(record.Text)))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,95 @@
+package converter
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"grunichat-onebot-adapter/internal/confirmation"
+	"grunichat-onebot-adapter/internal/formatter"
+	"grunichat-onebot-adapter/internal/history"
+	"grunichat-onebot-adapter/internal/types"
+)
+
+func TestParseHistoryArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		wantUser  int64
+		wantSince time.Duration
+		wantText  string
+	}{
+		{"empty", nil, 0, 0, ""},
+		{"user and since", []string{"user=12345", "since=2h"}, 12345, 2 * time.Hour, ""},
+		{"days", []string{"since=1d"}, 0, 24 * time.Hour, ""},
+		{"keywords", []string{"hello", "world"}, 0, 0, "hello world"},
+		{"invalid arguments become keywords", []string{"user=abc", "since=0m", "since=5s", "x"}, 0, 0, "user=abc since=0m since=5s x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := parseHistoryArgs(tt.args)
+			if query.UserID != tt.wantUser || query.Text != tt.wantText {
+				t.Errorf("parseHistoryArgs(%q) = user %d text %q, want user %d text %q", tt.args, query.UserID, query.Text, tt.wantUser, tt.wantText)
+			}
+			if tt.wantSince == 0 {
+				if !query.Since.IsZero() {
+					t.Errorf("parseHistoryArgs(%q) since = %v, want zero", tt.args, query.Since)
+				}
+				return
+			}
+			if since := time.Since(query.Since); since < tt.wantSince || since > tt.wantSince+time.Minute {
+				t.Errorf("parseHistoryArgs(%q) since %v ago, want %v", tt.args, since, tt.wantSince)
+			}
+		})
+	}
+}
+
+func TestFormatHistoryReplyEscapesRecords(t *testing.T) {
+	mc, _ := newTestConverter(t, converterConfig())
+	at := time.Date(2024, 5, 1, 12, 30, 0, 0, time.Local)
+
+	// 查询结果按时间从新到旧排列
+	records := []history.Record{
+		{Direction: history.DirectionOutbound, Text: "<Alex> [doge] & [CQ:at,qq=all]", Time: at},
+		{Direction: history.DirectionInbound, Sender: "[CQ:at,qq=all]", Text: "[CQ:image,file=x]", Time: at},
+	}
+	got := mc.formatHistoryReply(&types.OneBotMessage{GroupID: 100}, records)
+	want := "最近 2 条消息记录：\n" +
+		"[05-01 12:30] &#91;CQ:at,qq=all&#93;: &#91;CQ:image,file=x&#93;\n" +
+		"[05-01 12:30] <Alex> &#91;doge&#93; &amp; &#91;CQ:at,qq=all&#93;"
+	if got != want {
+		t.Errorf("formatHistoryReply() = %q, want %q", got, want)
+	}
+}
+
+func TestHistoryCommandRepliesWithEscapedRecords(t *testing.T) {
+	cfg := converterConfig()
+	cfg.Command.RequirePermission = false
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	store, err := history.NewStore(t.TempDir(), 0, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	onebotSender := &recordingSender{}
+	fmt := formatter.NewMessageFormatter(cfg, logger)
+	mc := NewMessageConverter(cfg, logger, fmt, confirmation.NewCommandConfirmationManager(fmt, onebotSender, nil, logger), onebotSender, nil, store)
+
+	// 出站历史保存转义前的文本，查询时统一转义一次
+	mc.GRUniChatToOneBot(context.Background(), gameChat("[CQ:at,qq=all]"))
+	mc.OneBotToGRUniChat(context.Background(), groupMessage(100, 5, "!!history"))
+
+	sent := onebotSender.sent()
+	if len(sent) != 2 {
+		t.Fatalf("sent %q, want the chat and the history reply", sent)
+	}
+	if want := "&#91;CQ:at,qq=all&#93;"; !strings.HasSuffix(sent[1], "] "+want) {
+		t.Errorf("history reply = %q, want the record escaped once as %q", sent[1], want)
+	}
+}
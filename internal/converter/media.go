@@ -0,0 +1,118 @@
+package converter
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"grunichat-onebot-adapter/internal/i18n"
+	"grunichat-onebot-adapter/internal/media"
+	"grunichat-onebot-adapter/internal/middleware"
+)
+
+// OneBot字符串消息中的媒体CQ码，例如 [CQ:image,file=xxx.jpg,url=...]
+var cqMediaPattern = regexp.MustCompile(`\[CQ:(image|video|file)((?:,[^\]]*)?)\]`)
+
+// 媒体消息段
+type mediaSegment struct {
+	segmentType string
+	data        map[string]interface{}
+}
+
+// 各类媒体消息段在文本中的占位符
+var mediaPlaceholderKeys = map[string]string{
+	"image": i18n.KeyMediaImage,
+	"video": i18n.KeyMediaVideo,
+	"file":  i18n.KeyMediaFile,
+}
+
+// 获取媒体消息段的占位文本
+func (mc *MessageConverter) mediaPlaceholder(groupID int64, segmentType string) string {
+	return mc.formatter.Localize(groupID, mediaPlaceholderKeys[segmentType], nil)
+}
+
+// 将字符串消息中的媒体CQ码替换为占位文本
+func (mc *MessageConverter) replaceCQMedia(groupID int64, message string) string {
+	return cqMediaPattern.ReplaceAllStringFunc(message, func(match string) string {
+		return mc.mediaPlaceholder(groupID, cqMediaPattern.FindStringSubmatch(match)[1])
+	})
+}
+
+// 解析CQ码参数
+func parseCQParams(params string) map[string]interface{} {
+	data := make(map[string]interface{})
+	for _, pair := range strings.Split(strings.TrimPrefix(params, ","), ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		value := strings.NewReplacer("&#44;", ",", "&#91;", "[", "&#93;", "]", "&amp;", "&").Replace(kv[1])
+		data[kv[0]] = value
+	}
+	return data
+}
+
+// 收集消息中的媒体消息段（支持字符串和数组两种格式）
+func collectMediaSegments(message interface{}) []mediaSegment {
+	var segments []mediaSegment
+
+	switch msg := message.(type) {
+	case string:
+		for _, match := range cqMediaPattern.FindAllStringSubmatch(msg, -1) {
+			segments = append(segments, mediaSegment{segmentType: match[1], data: parseCQParams(match[2])})
+		}
+	case []interface{}:
+		for _, segment := range msg {
+			segmentMap, ok := segment.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			segmentType, _ := segmentMap["type"].(string)
+			data, _ := segmentMap["data"].(map[string]interface{})
+			if media.IsMediaSegment(segmentType) && data != nil {
+				segments = append(segments, mediaSegment{segmentType: segmentType, data: data})
+			}
+		}
+	}
+
+	return segments
+}
+
+// 转发消息中的媒体文件，链接写入extra.media并可附加到聊天文本（仅入站）
+func (mc *MessageConverter) mediaStage(ctx context.Context, env *middleware.Envelope, next middleware.Handler) error {
+	if env.Direction != middleware.DirectionInbound || mc.media == nil {
+		return next(ctx, env)
+	}
+
+	// 整条消息的媒体处理不超过 message_timeout，单个文件的下载超时由 media.fetch_timeout 控制
+	resolveCtx, cancel := context.WithTimeout(ctx, time.Duration(mc.config.Performance.MessageTimeout)*time.Second)
+	defer cancel()
+
+	var items []*media.Item
+	for _, segment := range collectMediaSegments(env.OneBot.Message) {
+		item, err := mc.media.Resolve(resolveCtx, segment.segmentType, segment.data)
+		if err != nil {
+			mc.logger.Warnf("Failed to relay %s from user %d: %v", segment.segmentType, env.OneBot.UserID, err)
+			continue
+		}
+		items = append(items, item)
+	}
+
+	if len(items) > 0 {
+		if env.GRUniChat.Extra == nil {
+			env.GRUniChat.Extra = make(map[string]interface{})
+		}
+		env.GRUniChat.Extra["media"] = items
+
+		if mc.config.Media.LinkInText {
+			links := make([]string, 0, len(items))
+			for _, item := range items {
+				links = append(links, item.URL)
+			}
+			env.Text = strings.TrimSpace(env.Text + " " + strings.Join(links, " "))
+		}
+	}
+
+	return next(ctx, env)
+}
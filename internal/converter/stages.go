@@ -0,0 +1,189 @@
+package converter
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"grunichat-onebot-adapter/internal/contentfilter"
+	"grunichat-onebot-adapter/internal/metrics"
+	"grunichat-onebot-adapter/internal/middleware"
+)
+
+// 其他过滤原因
+const (
+	FilterReasonAntiSpam           = "anti_spam"            // 防刷屏
+	FilterReasonMessageTypeBlocked = "message_type_blocked" // 目标群不接收该类型的消息
+	filterReasonContentPrefix      = "content_filter:"      // 内容过滤规则，后接规则名称
+)
+
+// 内置阶段名称
+const (
+	StageFilter        = "filter"         // 消息类型、黑名单、服务群过滤
+	StageConfirmation  = "confirmation"   // 命令确认回复处理
+	StageAntiSpam      = "anti_spam"      // 入站防刷屏
+	StageHistory       = "history"        // !!history 历史查询命令
+	StageContentFilter = "content_filter" // 内容过滤规则
+	StageMedia         = "media"          // 媒体文件转发
+	StageMinecraft     = "minecraft"      // Minecraft格式代码与JSON文本组件转换
+	StageCommand       = "command"        // !!command 命令解析
+	StageFormat        = "format"         // 消息格式化
+)
+
+// 各方向必须包含的阶段，缺少时消息会绕过服务群、黑名单或内容过滤
+var requiredStages = map[string][]string{
+	middleware.DirectionInbound:  {StageFilter, StageContentFilter},
+	middleware.DirectionOutbound: {StageContentFilter},
+}
+
+// 检查阶段列表是否包含指定阶段
+func hasStage(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// 注册内置阶段
+func (mc *MessageConverter) registerBuiltinStages(registry *middleware.Registry) {
+	registry.Register(StageFilter, mc.filterStage)
+	registry.Register(StageConfirmation, mc.confirmationStage)
+	registry.Register(StageAntiSpam, mc.antiSpamStage)
+	registry.Register(StageHistory, mc.historyStage)
+	registry.Register(StageContentFilter, mc.contentFilterStage)
+	registry.Register(StageMedia, mc.mediaStage)
+	registry.Register(StageMinecraft, mc.minecraftStage)
+	registry.Register(StageCommand, mc.commandStage)
+	registry.Register(StageFormat, mc.formatStage)
+}
+
+// 统计阶段耗时（不包含后续阶段的耗时）
+func timedStage(direction, name string, mw middleware.Middleware) middleware.Middleware {
+	return func(ctx context.Context, env *middleware.Envelope, next middleware.Handler) error {
+		var nextElapsed time.Duration
+		start := time.Now()
+		err := mw(ctx, env, func(ctx context.Context, env *middleware.Envelope) error {
+			nextStart := time.Now()
+			err := next(ctx, env)
+			nextElapsed += time.Since(nextStart)
+			return err
+		})
+		metrics.StageDuration.Observe((time.Since(start) - nextElapsed).Seconds(), direction, name)
+		return err
+	}
+}
+
+// 记录被过滤的消息
+func countFiltered(env *middleware.Envelope, reason string) {
+	metrics.FilteredMessagesTotal.Inc(env.Direction, reason)
+}
+
+// 检查文本是否为命令
+func isCommandText(text string) bool {
+	return strings.HasPrefix(text, "!!command ")
+}
+
+// 过滤消息（仅入站）
+func (mc *MessageConverter) filterStage(ctx context.Context, env *middleware.Envelope, next middleware.Handler) error {
+	if env.Direction == middleware.DirectionInbound {
+		if reason := mc.filter.FilterReason(env.OneBot); reason != "" {
+			countFiltered(env, reason)
+			return nil
+		}
+	}
+	return next(ctx, env)
+}
+
+// 处理确认回复（仅入站）
+func (mc *MessageConverter) confirmationStage(ctx context.Context, env *middleware.Envelope, next middleware.Handler) error {
+	if env.Direction == middleware.DirectionInbound && mc.confirmationManager.HandleConfirmationReply(env.OneBot, env.Text) {
+		return nil // 确认回复已处理，不需要转发
+	}
+	return next(ctx, env)
+}
+
+// 防刷屏检查与长度截断（仅入站）
+func (mc *MessageConverter) antiSpamStage(ctx context.Context, env *middleware.Envelope, next middleware.Handler) error {
+	if env.Direction != middleware.DirectionInbound {
+		return next(ctx, env)
+	}
+
+	if mc.filter.ShouldThrottle(env.OneBot, env.Text) {
+		countFiltered(env, FilterReasonAntiSpam)
+		return nil
+	}
+	if !isCommandText(env.Text) {
+		env.Text = mc.filter.TruncateMessage(env.Text)
+	}
+	return next(ctx, env)
+}
+
+// 内容过滤（双向）
+func (mc *MessageConverter) contentFilterStage(ctx context.Context, env *middleware.Envelope, next middleware.Handler) error {
+	var result contentfilter.Result
+	if env.Direction == middleware.DirectionInbound {
+		messageType := "chat"
+		if isCommandText(env.Text) {
+			messageType = "command"
+		}
+		result = mc.contentFilter.Apply(contentfilter.DirectionInbound, env.GroupID, messageType, env.Text)
+	} else {
+		result = mc.contentFilter.Apply(contentfilter.DirectionOutbound, env.GroupID, env.GRUniChat.Type, env.Text)
+	}
+
+	if result.Dropped {
+		mc.logger.Debugf("Filtered %s message in group %d by rule %s", env.Direction, env.GroupID, result.Rule)
+		countFiltered(env, filterReasonContentPrefix+result.Rule)
+		return nil
+	}
+	env.Text = result.Text
+	return next(ctx, env)
+}
+
+// 处理§格式代码和JSON文本组件（仅出站）
+func (mc *MessageConverter) minecraftStage(ctx context.Context, env *middleware.Envelope, next middleware.Handler) error {
+	if env.Direction == middleware.DirectionOutbound {
+		env.Text = mc.formatter.MinecraftToPlainText(env.Text)
+	}
+	return next(ctx, env)
+}
+
+// 解析 !!command 命令（仅入站）
+func (mc *MessageConverter) commandStage(ctx context.Context, env *middleware.Envelope, next middleware.Handler) error {
+	if env.Direction != middleware.DirectionInbound || !isCommandText(env.Text) {
+		return next(ctx, env)
+	}
+
+	if mc.handleCommand(env.OneBot, env.SenderName, env.Text, env.GRUniChat) == nil {
+		return nil // 无权限或等待确认，不转发
+	}
+	return next(ctx, env)
+}
+
+// 格式化消息（双向）
+func (mc *MessageConverter) formatStage(ctx context.Context, env *middleware.Envelope, next middleware.Handler) error {
+	if env.Direction == middleware.DirectionInbound {
+		if env.GRUniChat.Type == "" {
+			mc.buildChatMessage(env, mc.formatter.FormatOneBotGroupMessage(env.OneBot, env.Text))
+		}
+		return next(ctx, env)
+	}
+
+	// 检查目标群是否接收该类型的消息
+	if !mc.formatter.AllowsMessageType(env.GroupID, env.GRUniChat.From, env.GRUniChat.Type) {
+		mc.logger.Debugf("Group %d does not accept %s messages from %s, skipping", env.GroupID, env.GRUniChat.Type, env.GRUniChat.From)
+		countFiltered(env, FilterReasonMessageTypeBlocked)
+		return nil
+	}
+
+	if env.GRUniChat.Type == "event" {
+		// 事件消息格式，默认为：<[客户端]> 事件详情
+		env.Text = mc.formatter.FormatEventMessageForOneBot(env.GRUniChat, env.GroupID, env.Text)
+	} else {
+		// 聊天消息格式，默认为：<[客户端] 用户名> 消息内容
+		env.Text = mc.formatter.FormatChatMessageForOneBot(env.GRUniChat, env.GroupID, env.Text)
+	}
+	return next(ctx, env)
+}
@@ -0,0 +1,86 @@
+package emoji
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 游戏消息中的表情短代码，例如 [微笑]
+var faceShortcodePattern = regexp.MustCompile(`\[([^\[\]]{1,8})\]`)
+
+// OneBot字符串消息中的表情CQ码，例如 [CQ:face,id=14]
+var faceCQCodePattern = regexp.MustCompile(`\[CQ:face,id=(\d+)[^\]]*\]`)
+
+// 表情名称到ID的反向映射
+var faceIDs = func() map[string]int {
+	ids := make(map[string]int, len(faceNames))
+	for id, name := range faceNames {
+		ids[name] = id
+	}
+	return ids
+}()
+
+// emoji替换器，按emoji长度从长到短匹配
+var emojiReplacer = func() *strings.Replacer {
+	pairs := make([]string, 0, len(emojiShortcodes)*4)
+	for emoji, shortcode := range emojiShortcodes {
+		// 同时处理带有变体选择符（U+FE0F）的形式
+		pairs = append(pairs, emoji+"\ufe0f", shortcode, emoji, shortcode)
+	}
+	return strings.NewReplacer(pairs...)
+}()
+
+// 获取QQ表情的显示文本，例如 [微笑]，未知表情显示为 [表情]
+func FaceText(id int) string {
+	if name, exists := faceNames[id]; exists {
+		return "[" + name + "]"
+	}
+	return "[表情]"
+}
+
+// 将字符串消息中的表情CQ码替换为显示文本
+func CQFacesToText(message string) string {
+	return faceCQCodePattern.ReplaceAllStringFunc(message, func(match string) string {
+		id, err := strconv.Atoi(faceCQCodePattern.FindStringSubmatch(match)[1])
+		if err != nil {
+			return match
+		}
+		return FaceText(id)
+	})
+}
+
+// 将Unicode emoji替换为文字短代码
+func EmojiToShortcodes(text string) string {
+	return emojiReplacer.Replace(text)
+}
+
+// 将游戏消息中的表情短代码转换为表情CQ码，其余文本一律按CQ码规则转义，
+// 避免游戏内输入的 [CQ:...] 被当作CQ码发送到QQ
+func ShortcodesToCQFaces(text string) string {
+	matches := faceShortcodePattern.FindAllStringSubmatchIndex(text, -1)
+
+	var builder strings.Builder
+	last := 0
+	for _, match := range matches {
+		id, exists := faceIDs[text[match[2]:match[3]]]
+		if !exists {
+			continue
+		}
+		builder.WriteString(EscapeCQText(text[last:match[0]]))
+		builder.WriteString(fmt.Sprintf("[CQ:face,id=%d]", id))
+		last = match[1]
+	}
+	builder.WriteString(EscapeCQText(text[last:]))
+	return builder.String()
+}
+
+// 按OneBot字符串消息格式转义文本，转义后的文本不会被解析为CQ码
+// 逗号只在CQ码参数中需要转义，纯文本中转义会被部分实现原样显示
+func EscapeCQText(text string) string {
+	text = strings.ReplaceAll(text, "&", "&amp;")
+	text = strings.ReplaceAll(text, "[", "&#91;")
+	text = strings.ReplaceAll(text, "]", "&#93;")
+	return text
+}
@@ -0,0 +1,30 @@
+package emoji
+
+import "testing"
+
+func TestShortcodesToCQFaces(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"known shortcode", "hi [微笑]", "hi [CQ:face,id=14]"},
+		{"unknown shortcode", "lol [x]", "lol &#91;x&#93;"},
+		{"cq code without face", "[CQ:at,qq=all]", "&#91;CQ:at,qq=all&#93;"},
+		{"cq code with face", "[doge] [CQ:at,qq=all] &", "[CQ:face,id=179] &#91;CQ:at,qq=all&#93; &amp;"},
+		{"plain text", "hello", "hello"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ShortcodesToCQFaces(tt.text); got != tt.want {
+				t.Errorf("ShortcodesToCQFaces(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCQFacesToText(t *testing.T) {
+	if got, want := CQFacesToText("a[CQ:face,id=179]b[CQ:face,id=9999]"), "a[doge]b[表情]"; got != want {
+		t.Errorf("CQFacesToText() = %q, want %q", got, want)
+	}
+}
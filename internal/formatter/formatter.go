@@ -3,44 +3,170 @@ package formatter
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"grunichat-onebot-adapter/internal/config"
+	"grunichat-onebot-adapter/internal/i18n"
+	"grunichat-onebot-adapter/internal/types"
 )
 
 // 消息格式化器
 type MessageFormatter struct {
-	config *config.Config
-	logger *logrus.Logger
+	config  *config.Config
+	logger  *logrus.Logger
+	catalog *i18n.Catalog
 }
 
 // 创建消息格式化器
 func NewMessageFormatter(cfg *config.Config, logger *logrus.Logger) *MessageFormatter {
 	return &MessageFormatter{
-		config: cfg,
-		logger: logger,
+		config:  cfg,
+		logger:  logger,
+		catalog: i18n.NewCatalog(cfg, logger),
+	}
+}
+
+// 获取消息目录
+func (mf *MessageFormatter) Catalog() *i18n.Catalog {
+	return mf.catalog
+}
+
+// 获取指定群语言的适配器提示文本
+func (mf *MessageFormatter) Localize(groupID int64, key string, vars map[string]string) string {
+	return mf.catalog.Text(groupID, key, vars)
+}
+
+// 渲染模板，未知变量保持原样
+func (mf *MessageFormatter) Render(template string, vars map[string]string) string {
+	return i18n.Render(template, vars)
+}
+
+// 按 群覆盖 → 客户端覆盖 → 全局配置 的顺序解析模板，clientID 为空时跳过客户端覆盖
+func (mf *MessageFormatter) resolveTemplate(groupID int64, clientID string, pick func(config.FormatOverrideConfig) string, global string) string {
+	if override, exists := mf.config.Format.Groups[groupID]; exists && pick(override) != "" {
+		return pick(override)
+	}
+	if clientID != "" {
+		if override, exists := mf.config.Format.Clients[clientID]; exists && pick(override) != "" {
+			return pick(override)
+		}
+	}
+	return global
+}
+
+// 检查指定群是否接收来自指定客户端的该类型消息
+func (mf *MessageFormatter) AllowsMessageType(groupID int64, clientID, messageType string) bool {
+	messageTypes := mf.config.Format.Groups[groupID].MessageTypes
+	if len(messageTypes) == 0 {
+		messageTypes = mf.config.Format.Clients[clientID].MessageTypes
+	}
+	if len(messageTypes) == 0 {
+		return true
+	}
+
+	for _, allowed := range messageTypes {
+		if allowed == messageType {
+			return true
+		}
+	}
+	return false
+}
+
+// 获取群名称，未配置时使用群ID
+func (mf *MessageFormatter) groupName(groupID int64) string {
+	if name, exists := mf.config.Format.GroupNames[groupID]; exists {
+		return name
+	}
+	return fmt.Sprintf("%d", groupID)
+}
+
+// 构建OneBot消息的模板变量
+func (mf *MessageFormatter) oneBotVariables(onebot *types.OneBotMessage, message string) map[string]string {
+	sender := onebot.Sender.Nickname
+	if onebot.Sender.Card != "" {
+		sender = onebot.Sender.Card
+	}
+
+	now := time.Now()
+	if onebot.Time != 0 {
+		now = time.Unix(onebot.Time, 0)
+	}
+
+	return map[string]string{
+		"sender":     sender,
+		"nickname":   onebot.Sender.Nickname,
+		"card":       onebot.Sender.Card,
+		"role":       onebot.Sender.Role,
+		"title":      onebot.Sender.Title,
+		"user_id":    fmt.Sprintf("%d", onebot.UserID),
+		"group_id":   fmt.Sprintf("%d", onebot.GroupID),
+		"group_name": mf.groupName(onebot.GroupID),
+		"client_id":  mf.config.GRUniChat.ClientID,
+		"time":       now.Format("15:04:05"),
+		"date":       now.Format("2006-01-02"),
+		"message":    message,
+	}
+}
+
+// 构建GRUniChat消息的模板变量
+func (mf *MessageFormatter) gruniChatVariables(gruni *types.GRUniChatMessage, groupID int64, message string) map[string]string {
+	now, err := time.ParseInLocation("2006-01-02 15:04:05", gruni.CurrentTime, time.Local)
+	if err != nil {
+		now = time.Now()
+	}
+
+	return map[string]string{
+		"sender":     mf.MinecraftToPlainText(gruni.Body.Sender),
+		"client_id":  gruni.From,
+		"group_id":   fmt.Sprintf("%d", groupID),
+		"group_name": mf.groupName(groupID),
+		"time":       now.Format("15:04:05"),
+		"date":       now.Format("2006-01-02"),
+		"message":    message,
 	}
 }
 
 // 格式化OneBot群消息
-func (mf *MessageFormatter) FormatOneBotGroupMessage(message string) string {
-	format := mf.config.Format.GroupMessageFormat
-	format = strings.ReplaceAll(format, "{message}", message)
-	return format
+func (mf *MessageFormatter) FormatOneBotGroupMessage(onebot *types.OneBotMessage, message string) string {
+	template := mf.resolveTemplate(onebot.GroupID, "", func(o config.FormatOverrideConfig) string {
+		return o.GroupMessageFormat
+	}, mf.config.Format.GroupMessageFormat)
+
+	// 启用显示群ID且模板中没有引用群信息时，在消息前添加群标识
+	if mf.config.Format.ShowGroupID && !strings.Contains(template, "{group_id}") && !strings.Contains(template, "{group_name}") {
+		template = "[{group_name}] " + template
+	}
+
+	return mf.Render(template, mf.oneBotVariables(onebot, message))
 }
 
 // 格式化发送到OneBot的聊天消息
-func (mf *MessageFormatter) FormatChatMessageForOneBot(from, sender, message string) string {
-	return fmt.Sprintf("<[%s] %s> %s", from, sender, message)
+func (mf *MessageFormatter) FormatChatMessageForOneBot(gruni *types.GRUniChatMessage, groupID int64, message string) string {
+	template := mf.resolveTemplate(groupID, gruni.From, func(o config.FormatOverrideConfig) string {
+		return o.ChatMessageFormat
+	}, mf.config.Format.ChatMessageFormat)
+	return mf.Render(template, mf.gruniChatVariables(gruni, groupID, message))
 }
 
 // 格式化发送到OneBot的事件消息
-func (mf *MessageFormatter) FormatEventMessageForOneBot(from, eventDetail string) string {
-	return fmt.Sprintf("<[%s]> %s", from, eventDetail)
+func (mf *MessageFormatter) FormatEventMessageForOneBot(gruni *types.GRUniChatMessage, groupID int64, eventDetail string) string {
+	template := mf.resolveTemplate(groupID, gruni.From, func(o config.FormatOverrideConfig) string {
+		return o.EventMessageFormat
+	}, mf.config.Format.EventMessageFormat)
+	return mf.Render(template, mf.gruniChatVariables(gruni, groupID, eventDetail))
 }
 
 // 格式化确认消息
-func (mf *MessageFormatter) FormatConfirmationMessage(senderName, command string) string {
-	return fmt.Sprintf("@%s 您要执行命令：%s\n请回复 '确认' 或 '取消'", senderName, command)
+func (mf *MessageFormatter) FormatConfirmationMessage(onebot *types.OneBotMessage, command string) string {
+	vars := mf.oneBotVariables(onebot, command)
+	vars["command"] = command
+	template := mf.resolveTemplate(onebot.GroupID, "", func(o config.FormatOverrideConfig) string {
+		return o.ConfirmationFormat
+	}, mf.config.Format.ConfirmationFormat)
+	if template == "" {
+		template = mf.Localize(onebot.GroupID, i18n.KeyConfirmationPrompt, nil)
+	}
+	return mf.Render(template, vars)
 }
@@ -0,0 +1,181 @@
+package formatter
+
+import (
+	"testing"
+
+	"grunichat-onebot-adapter/internal/config"
+	"grunichat-onebot-adapter/internal/types"
+)
+
+func groupMessage(groupID int64) *types.OneBotMessage {
+	return &types.OneBotMessage{
+		GroupID: groupID,
+		UserID:  10001,
+		Sender:  types.OneBotSender{Nickname: "steve", Card: "Steve", Role: "admin", Title: "OP"},
+	}
+}
+
+func TestRender(t *testing.T) {
+	mf := newTestFormatter(t)
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"known variables", "<{sender}> {message}", "<Steve> hi {sender}"},
+		{"unknown variable kept", "{message} {unknown}", "hi {sender} {unknown}"},
+		{"unclosed brace", "{sender", "{sender"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 变量值中的占位符不会被再次展开
+			got := mf.Render(tt.template, map[string]string{"sender": "Steve", "message": "hi {sender}"})
+			if got != tt.want {
+				t.Errorf("Render(%q) = %q, want %q", tt.template, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatOneBotGroupMessage(t *testing.T) {
+	mf := newTestFormatter(t)
+	mf.config.Format.GroupNames = map[int64]string{100: "服务器群"}
+
+	tests := []struct {
+		name        string
+		template    string
+		showGroupID bool
+		want        string
+	}{
+		{"sender variables", "[{role}|{title}] {sender}({nickname}) {user_id}: {message}", false, "[admin|OP] Steve(steve) 10001: hi"},
+		{"show group id", "{sender}: {message}", true, "[服务器群] Steve: hi"},
+		{"template references group", "{group_id} {sender}: {message}", true, "100 Steve: hi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mf.config.Format.GroupMessageFormat = tt.template
+			mf.config.Format.ShowGroupID = tt.showGroupID
+			if got := mf.FormatOneBotGroupMessage(groupMessage(100), "hi"); got != tt.want {
+				t.Errorf("FormatOneBotGroupMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatMessagesForOneBot(t *testing.T) {
+	mf := newTestFormatter(t)
+	mf.config.Format.ChatMessageFormat = "[{client_id}] <{sender}> {message}"
+	mf.config.Format.EventMessageFormat = "{date} {time} [{client_id}] {message}"
+
+	gruni := &types.GRUniChatMessage{
+		From:        "survival",
+		CurrentTime: "2024-05-01 12:30:00",
+		Body:        types.GRUniChatBody{Sender: "§aAlex"},
+	}
+	if got, want := mf.FormatChatMessageForOneBot(gruni, 100, "hi"), "[survival] <Alex> hi"; got != want {
+		t.Errorf("chat = %q, want %q", got, want)
+	}
+	if got, want := mf.FormatEventMessageForOneBot(gruni, 100, "Alex joined"), "2024-05-01 12:30:00 [survival] Alex joined"; got != want {
+		t.Errorf("event = %q, want %q", got, want)
+	}
+}
+
+func TestFormatConfirmationMessage(t *testing.T) {
+	mf := newTestFormatter(t)
+	mf.config.Format.ConfirmationFormat = "{sender} -> {command}"
+
+	if got, want := mf.FormatConfirmationMessage(groupMessage(100), "say hi"), "Steve -> say hi"; got != want {
+		t.Errorf("FormatConfirmationMessage() = %q, want %q", got, want)
+	}
+}
+
+func TestGroupMessageFormatOverride(t *testing.T) {
+	mf := newTestFormatter(t)
+	mf.config.Format.GroupMessageFormat = "{sender}: {message}"
+	mf.config.Format.Groups = map[int64]config.FormatOverrideConfig{
+		200: {GroupMessageFormat: "{group_name}|{user_id}|{message}"},
+	}
+
+	if got, want := mf.FormatOneBotGroupMessage(groupMessage(200), "hi"), "200|10001|hi"; got != want {
+		t.Errorf("group override = %q, want %q", got, want)
+	}
+	if got, want := mf.FormatOneBotGroupMessage(groupMessage(100), "hi"), "Steve: hi"; got != want {
+		t.Errorf("global template = %q, want %q", got, want)
+	}
+}
+
+func TestChatMessageFormatOverrides(t *testing.T) {
+	mf := newTestFormatter(t)
+	mf.config.Format.ChatMessageFormat = "[{client_id}] <{sender}> {message}"
+	mf.config.Format.Groups = map[int64]config.FormatOverrideConfig{
+		200: {ChatMessageFormat: "group: {message}"},
+		300: {EventMessageFormat: "group event: {message}"},
+	}
+	mf.config.Format.Clients = map[string]config.FormatOverrideConfig{
+		"survival": {ChatMessageFormat: "survival: {message}"},
+	}
+
+	survival := &types.GRUniChatMessage{From: "survival", Body: types.GRUniChatBody{Sender: "Alex"}}
+	lobby := &types.GRUniChatMessage{From: "lobby", Body: types.GRUniChatBody{Sender: "Alex"}}
+
+	tests := []struct {
+		name    string
+		gruni   *types.GRUniChatMessage
+		groupID int64
+		want    string
+	}{
+		{"group override wins over client", survival, 200, "group: hi"},
+		{"client override", survival, 100, "survival: hi"},
+		{"empty group field falls back to client", survival, 300, "survival: hi"},
+		{"global template", lobby, 100, "[lobby] <Alex> hi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mf.FormatChatMessageForOneBot(tt.gruni, tt.groupID, "hi"); got != tt.want {
+				t.Errorf("FormatChatMessageForOneBot() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowsMessageType(t *testing.T) {
+	mf := newTestFormatter(t)
+	mf.config.Format.Groups = map[int64]config.FormatOverrideConfig{
+		200: {MessageTypes: []string{"chat"}},
+	}
+	mf.config.Format.Clients = map[string]config.FormatOverrideConfig{
+		"survival": {MessageTypes: []string{"event"}},
+	}
+
+	tests := []struct {
+		groupID     int64
+		clientID    string
+		messageType string
+		want        bool
+	}{
+		{200, "survival", "chat", true},
+		{200, "survival", "event", false},
+		{100, "survival", "chat", false},
+		{100, "survival", "event", true},
+		{100, "lobby", "chat", true},
+	}
+	for _, tt := range tests {
+		if got := mf.AllowsMessageType(tt.groupID, tt.clientID, tt.messageType); got != tt.want {
+			t.Errorf("AllowsMessageType(%d, %q, %q) = %v, want %v", tt.groupID, tt.clientID, tt.messageType, got, tt.want)
+		}
+	}
+}
+
+func TestFormatConfirmationMessageUsesGroupLocale(t *testing.T) {
+	mf := newTestFormatter(t)
+	mf.config.Format.ConfirmationFormat = ""
+	mf.config.I18n.GroupLocales = map[int64]string{200: "en-US"}
+
+	if got, want := mf.FormatConfirmationMessage(groupMessage(100), "say hi"), "@Steve 您要执行命令：say hi\n请回复 '确认' 或 '取消'"; got != want {
+		t.Errorf("zh-CN prompt = %q, want %q", got, want)
+	}
+	if got, want := mf.FormatConfirmationMessage(groupMessage(200), "say hi"), "@Steve You are about to run: say hi\nReply 'confirm' or 'cancel'"; got != want {
+		t.Errorf("en-US prompt = %q, want %q", got, want)
+	}
+}
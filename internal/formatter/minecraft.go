@@ -0,0 +1,208 @@
+package formatter
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Minecraft格式代码（颜色、样式及 §x 十六进制颜色）
+var minecraftCodePattern = regexp.MustCompile(`(?i)§[0-9a-fk-orx]`)
+
+// 样式代码在QQ中的文字标记，仅在 formatting_codes 为 translate 时使用
+var minecraftStyleNames = map[byte]string{
+	'l': "**", // 粗体
+	'o': "_",  // 斜体
+	'm': "~~", // 删除线
+}
+
+// 常见翻译键的显示格式
+var translationFormats = map[string]string{
+	"chat.type.text":                  "<%s> %s",
+	"chat.type.announcement":          "[%s] %s",
+	"chat.type.emote":                 "* %s %s",
+	"multiplayer.player.joined":       "%s joined the game",
+	"multiplayer.player.left":         "%s left the game",
+	"chat.type.advancement.task":      "%s has made the advancement %s",
+	"chat.type.advancement.goal":      "%s has reached the goal %s",
+	"chat.type.advancement.challenge": "%s has completed the challenge %s",
+}
+
+// 将游戏文本转换为适合QQ显示的纯文本
+func (mf *MessageFormatter) MinecraftToPlainText(text string) string {
+	cfg := mf.config.Format.Minecraft
+
+	if cfg.ParseJSONComponents {
+		if plain, ok := parseTextComponent(text); ok {
+			text = plain
+		}
+	}
+
+	switch cfg.FormattingCodes {
+	case "keep":
+		return text
+	case "translate":
+		return translateMinecraftCodes(text)
+	default:
+		return minecraftCodePattern.ReplaceAllString(text, "")
+	}
+}
+
+// 将QQ群角色映射为游戏内的颜色代码，用于装饰发送者名称
+func (mf *MessageFormatter) ColorizeSender(senderName, role string) string {
+	color := mf.config.Format.Minecraft.RoleColors[role]
+	if color == "" {
+		return senderName
+	}
+	return color + senderName + "§r"
+}
+
+// 将样式代码翻译为对应的文字标记，颜色代码直接去除
+func translateMinecraftCodes(text string) string {
+	var builder strings.Builder
+	var openStyles []string
+
+	closeStyles := func() {
+		for i := len(openStyles) - 1; i >= 0; i-- {
+			builder.WriteString(openStyles[i])
+		}
+		openStyles = nil
+	}
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '§' || i+1 >= len(runes) {
+			builder.WriteRune(runes[i])
+			continue
+		}
+
+		code := strings.ToLower(string(runes[i+1]))
+		if !minecraftCodePattern.MatchString("§" + code) {
+			builder.WriteRune(runes[i])
+			continue
+		}
+		i++
+
+		if marker, exists := minecraftStyleNames[code[0]]; exists {
+			builder.WriteString(marker)
+			openStyles = append(openStyles, marker)
+			continue
+		}
+
+		// 颜色代码和 §r 会重置之前的样式
+		closeStyles()
+	}
+	closeStyles()
+
+	return builder.String()
+}
+
+// 解析JSON文本组件，返回拼接后的纯文本
+// 只有包含 text、translate 或 extra 字段的对象（或由这类对象组成的数组）才视为文本组件，
+// 玩家输入的 [1,2]、["hi"] 等普通JSON文本保持原样
+func parseTextComponent(text string) (string, bool) {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return "", false
+	}
+
+	var component interface{}
+	if err := json.Unmarshal([]byte(trimmed), &component); err != nil {
+		return "", false
+	}
+	if !isTextComponent(component) {
+		return "", false
+	}
+
+	var builder strings.Builder
+	flattenTextComponent(component, &builder)
+	return builder.String(), true
+}
+
+// 检查解析后的JSON是否为文本组件
+func isTextComponent(component interface{}) bool {
+	switch c := component.(type) {
+	case map[string]interface{}:
+		for _, key := range []string{"text", "translate", "extra"} {
+			if _, exists := c[key]; exists {
+				return true
+			}
+		}
+		return false
+	case []interface{}:
+		// 原版常见的 ["", {...}] 形式允许夹带字符串，但至少要有一个组件对象
+		found := false
+		for _, child := range c {
+			if _, ok := child.(string); ok {
+				continue
+			}
+			if !isTextComponent(child) {
+				return false
+			}
+			found = true
+		}
+		return found
+	default:
+		return false
+	}
+}
+
+// 递归展开文本组件
+func flattenTextComponent(component interface{}, builder *strings.Builder) {
+	switch c := component.(type) {
+	case string:
+		builder.WriteString(c)
+	case float64, bool:
+		builder.WriteString(fmt.Sprint(c))
+	case []interface{}:
+		for _, child := range c {
+			flattenTextComponent(child, builder)
+		}
+	case map[string]interface{}:
+		if text, ok := c["text"].(string); ok {
+			builder.WriteString(text)
+		} else if translate, ok := c["translate"].(string); ok {
+			builder.WriteString(translateComponent(translate, c["with"]))
+		} else if selector, ok := c["selector"].(string); ok {
+			builder.WriteString(selector)
+		} else if keybind, ok := c["keybind"].(string); ok {
+			builder.WriteString(keybind)
+		} else if score, ok := c["score"].(map[string]interface{}); ok {
+			if value, ok := score["value"]; ok {
+				builder.WriteString(fmt.Sprint(value))
+			}
+		}
+
+		if extra, ok := c["extra"].([]interface{}); ok {
+			for _, child := range extra {
+				flattenTextComponent(child, builder)
+			}
+		}
+	}
+}
+
+// 展开翻译组件，未知的翻译键以翻译键加参数的形式显示
+func translateComponent(key string, with interface{}) string {
+	args, _ := with.([]interface{})
+
+	parts := make([]string, 0, len(args))
+	for _, arg := range args {
+		var builder strings.Builder
+		flattenTextComponent(arg, &builder)
+		parts = append(parts, builder.String())
+	}
+
+	if format, exists := translationFormats[key]; exists && strings.Count(format, "%s") == len(parts) {
+		values := make([]interface{}, len(parts))
+		for i, part := range parts {
+			values[i] = part
+		}
+		return fmt.Sprintf(format, values...)
+	}
+
+	if len(parts) == 0 {
+		return key
+	}
+	return fmt.Sprintf("%s(%s)", key, strings.Join(parts, ", "))
+}
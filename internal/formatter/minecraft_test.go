@@ -0,0 +1,53 @@
+package formatter
+
+import (
+	"io"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"grunichat-onebot-adapter/internal/config"
+)
+
+func newTestFormatter(t *testing.T) *MessageFormatter {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Format.Minecraft.ParseJSONComponents = true
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewMessageFormatter(cfg, logger)
+}
+
+func TestMinecraftToPlainText(t *testing.T) {
+	mf := newTestFormatter(t)
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"formatting codes", "§aPlayer§r joined", "Player joined"},
+		{"translate component", `{"translate":"multiplayer.player.joined","with":[{"text":"Steve","color":"yellow"}]}`, "Steve joined the game"},
+		{"component array", `["",{"text":"hi "},{"text":"there","extra":[{"text":"!"}]}]`, "hi there!"},
+		{"number array", `[1,2]`, `[1,2]`},
+		{"string array", `["hi"]`, `["hi"]`},
+		{"plain object", `{"a":1}`, `{"a":1}`},
+		{"mixed array", `[{"text":"a"},1]`, `[{"text":"a"},1]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mf.MinecraftToPlainText(tt.text); got != tt.want {
+				t.Errorf("MinecraftToPlainText(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMinecraftToPlainTextTranslateStyles(t *testing.T) {
+	mf := newTestFormatter(t)
+	mf.config.Format.Minecraft.FormattingCodes = "translate"
+
+	if got, want := mf.MinecraftToPlainText("§lbold§r and §oit§ahello"), "**bold** and _it_hello"; got != want {
+		t.Errorf("MinecraftToPlainText() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,216 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"grunichat-onebot-adapter/internal/types"
+	"grunichat-onebot-adapter/internal/websocket"
+)
+
+// 整体状态
+const (
+	StatusOK          = "ok"          // 所有连接正常
+	StatusDegraded    = "degraded"    // OneBot正常，但GRUniChat未连接或机器人不在线
+	StatusUnavailable = "unavailable" // OneBot未连接
+)
+
+// 运行模式
+const (
+	ModeNormal     = "normal"      // 双向转发
+	ModeOneBotOnly = "onebot_only" // GRUniChat未连接，只连接了OneBot
+)
+
+// 未收到心跳间隔时使用的默认值
+const defaultHeartbeatInterval = 30 * time.Second
+
+// 单个连接的状态
+type ConnectionStatus struct {
+	Connected     bool       `json:"connected"`
+	LastMessageAt *time.Time `json:"last_message_at,omitempty"`
+	Error         string     `json:"error,omitempty"` // 停止自动重连的原因，例如认证被拒绝
+}
+
+// 机器人状态（来自OneBot心跳元事件）
+type BotStatus struct {
+	Known           bool       `json:"known"` // 是否收到过心跳或生命周期事件
+	Online          bool       `json:"online"`
+	Good            bool       `json:"good"`
+	Stale           bool       `json:"stale"` // 超过三个心跳间隔未收到心跳
+	LastHeartbeatAt *time.Time `json:"last_heartbeat_at,omitempty"`
+}
+
+// 健康检查报告
+type Report struct {
+	Status        string           `json:"status"`
+	Mode          string           `json:"mode"`
+	UptimeSeconds int64            `json:"uptime_seconds"`
+	OneBot        ConnectionStatus `json:"onebot"`
+	GRUniChat     ConnectionStatus `json:"grunichat"`
+	Bot           BotStatus        `json:"bot"`
+}
+
+// 健康状态监控
+type Monitor struct {
+	onebotWS    websocket.IWebSocketManager
+	grunichatWS websocket.IWebSocketManager
+	startedAt   time.Time
+
+	mu                   sync.Mutex
+	lastOneBotMessage    time.Time
+	lastGRUniChatMessage time.Time
+	botKnown             bool
+	botOnline            bool
+	botGood              bool
+	lastHeartbeat        time.Time
+	heartbeatInterval    time.Duration
+	connectionErrors     map[string]string // key: onebot, grunichat
+}
+
+// 创建健康状态监控
+func NewMonitor(onebotWS, grunichatWS websocket.IWebSocketManager) *Monitor {
+	return &Monitor{
+		onebotWS:          onebotWS,
+		grunichatWS:       grunichatWS,
+		startedAt:         time.Now(),
+		heartbeatInterval: defaultHeartbeatInterval,
+		connectionErrors:  make(map[string]string),
+	}
+}
+
+// 记录连接无法自动恢复的原因，err 为nil时清除；name 为 onebot 或 grunichat
+func (m *Monitor) SetConnectionError(name string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err == nil {
+		delete(m.connectionErrors, name)
+		return
+	}
+	m.connectionErrors[name] = err.Error()
+}
+
+// 记录收到OneBot消息
+func (m *Monitor) RecordOneBotMessage() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastOneBotMessage = time.Now()
+}
+
+// 记录收到GRUniChat消息
+func (m *Monitor) RecordGRUniChatMessage() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastGRUniChatMessage = time.Now()
+}
+
+// 处理OneBot元事件，更新机器人在线状态
+func (m *Monitor) HandleMetaEvent(event *types.OneBotMetaEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch event.MetaEventType {
+	case "heartbeat":
+		m.lastHeartbeat = time.Now()
+		if event.Interval > 0 {
+			m.heartbeatInterval = time.Duration(event.Interval) * time.Millisecond
+		}
+		if event.Status != nil {
+			m.botKnown = true
+			m.botOnline = event.Status.Online
+			m.botGood = event.Status.Good
+		}
+	case "lifecycle":
+		m.botKnown = true
+		switch event.SubType {
+		case "enable", "connect":
+			m.botOnline = true
+			m.botGood = true
+		case "disable":
+			m.botOnline = false
+		}
+	}
+}
+
+// 生成健康检查报告
+func (m *Monitor) Report() Report {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	report := Report{
+		UptimeSeconds: int64(time.Since(m.startedAt).Seconds()),
+		OneBot: ConnectionStatus{
+			Connected:     m.onebotWS.IsConnected(),
+			LastMessageAt: timePtr(m.lastOneBotMessage),
+			Error:         m.connectionErrors["onebot"],
+		},
+		GRUniChat: ConnectionStatus{
+			Connected:     m.grunichatWS.IsConnected(),
+			LastMessageAt: timePtr(m.lastGRUniChatMessage),
+			Error:         m.connectionErrors["grunichat"],
+		},
+		Bot: BotStatus{
+			Known:           m.botKnown,
+			Online:          m.botOnline,
+			Good:            m.botGood,
+			LastHeartbeatAt: timePtr(m.lastHeartbeat),
+		},
+	}
+
+	if !m.lastHeartbeat.IsZero() && time.Since(m.lastHeartbeat) > 3*m.heartbeatInterval {
+		report.Bot.Stale = true
+	}
+
+	report.Mode = ModeNormal
+	if !report.GRUniChat.Connected {
+		report.Mode = ModeOneBotOnly
+	}
+
+	botUnavailable := report.Bot.Known && (!report.Bot.Online || report.Bot.Stale)
+	switch {
+	case !report.OneBot.Connected:
+		report.Status = StatusUnavailable
+	case !report.GRUniChat.Connected || botUnavailable:
+		report.Status = StatusDegraded
+	default:
+		report.Status = StatusOK
+	}
+
+	return report
+}
+
+// 存活检查：进程在运行即返回200，连接断开由重连监控恢复，不应导致进程被重启；连接状态见就绪检查
+func (m *Monitor) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		writeReport(w, m.Report(), true)
+	})
+}
+
+// 就绪检查：所有连接正常且机器人在线时返回200，否则返回503
+func (m *Monitor) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		report := m.Report()
+		writeReport(w, report, report.Status == StatusOK)
+	})
+}
+
+// 输出JSON报告
+func writeReport(w http.ResponseWriter, report Report, healthy bool) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if healthy {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// 零值时间返回nil，用于省略JSON字段
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,67 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"grunichat-onebot-adapter/internal/types"
+)
+
+// 只提供连接状态的WebSocket管理器
+type fakeConnection struct {
+	connected bool
+}
+
+func (f *fakeConnection) Connect(ctx context.Context) error      { return nil }
+func (f *fakeConnection) SendMessage(message interface{}) error  { return nil }
+func (f *fakeConnection) SetMessageHandler(handler func([]byte)) {}
+func (f *fakeConnection) Close() error                           { return nil }
+func (f *fakeConnection) IsConnected() bool                      { return f.connected }
+
+func probe(t *testing.T, handler http.Handler) (int, Report) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var report Report
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatalf("invalid report: %v", err)
+	}
+	return recorder.Code, report
+}
+
+func TestProbes(t *testing.T) {
+	tests := []struct {
+		name       string
+		onebot     bool
+		grunichat  bool
+		botOnline  bool
+		wantStatus string
+		wantReady  int
+	}{
+		{"all connected", true, true, true, StatusOK, http.StatusOK},
+		{"onebot only", true, false, true, StatusDegraded, http.StatusServiceUnavailable},
+		{"bot offline", true, true, false, StatusDegraded, http.StatusServiceUnavailable},
+		{"onebot down", false, true, true, StatusUnavailable, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMonitor(&fakeConnection{connected: tt.onebot}, &fakeConnection{connected: tt.grunichat})
+			m.HandleMetaEvent(&types.OneBotMetaEvent{
+				MetaEventType: "heartbeat",
+				Status:        &types.OneBotStatus{Online: tt.botOnline, Good: true},
+			})
+
+			// 连接断开不影响存活检查
+			if code, report := probe(t, m.LivenessHandler()); code != http.StatusOK || report.Status != tt.wantStatus {
+				t.Errorf("liveness = %d %s, want 200 %s", code, report.Status, tt.wantStatus)
+			}
+			if code, _ := probe(t, m.ReadinessHandler()); code != tt.wantReady {
+				t.Errorf("readiness = %d, want %d", code, tt.wantReady)
+			}
+		})
+	}
+}
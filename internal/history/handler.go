@@ -0,0 +1,119 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// HTTP查询默认和最大返回条数
+const (
+	defaultQueryLimit = 100
+	maxQueryLimit     = 1000
+)
+
+// 支持的时间参数格式
+var queryTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"}
+
+// 查询接口HTTP处理器
+//
+// 参数：direction, group_id, user_id, client_id, since, until, text, limit
+func (s *Store) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query, err := parseQuery(req.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		records, err := s.Query(query)
+		if err != nil {
+			s.logger.Errorf("History query failed: %v", err)
+			http.Error(w, "history query failed", http.StatusInternalServerError)
+			return
+		}
+		if records == nil {
+			records = []Record{}
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"count":   len(records),
+			"records": records,
+		})
+	})
+}
+
+// 解析HTTP查询参数
+func parseQuery(values url.Values) (Query, error) {
+	query := Query{
+		Direction: values.Get("direction"),
+		ClientID:  values.Get("client_id"),
+		Text:      values.Get("text"),
+		Limit:     defaultQueryLimit,
+	}
+
+	if query.Direction != "" && query.Direction != DirectionInbound && query.Direction != DirectionOutbound {
+		return query, fmt.Errorf("invalid direction: %s", query.Direction)
+	}
+
+	var err error
+	if query.GroupID, err = parseInt(values, "group_id"); err != nil {
+		return query, err
+	}
+	if query.UserID, err = parseInt(values, "user_id"); err != nil {
+		return query, err
+	}
+	if query.Since, err = parseTime(values, "since"); err != nil {
+		return query, err
+	}
+	if query.Until, err = parseTime(values, "until"); err != nil {
+		return query, err
+	}
+
+	if limit, err := parseInt(values, "limit"); err != nil {
+		return query, err
+	} else if limit > 0 {
+		query.Limit = int(limit)
+	}
+	if query.Limit > maxQueryLimit {
+		query.Limit = maxQueryLimit
+	}
+
+	return query, nil
+}
+
+// 解析整数参数，参数不存在时返回0
+func parseInt(values url.Values, name string) (int64, error) {
+	raw := values.Get(name)
+	if raw == "" {
+		return 0, nil
+	}
+	value, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", name, raw)
+	}
+	return value, nil
+}
+
+// 解析时间参数，参数不存在时返回零值
+func parseTime(values url.Values, name string) (time.Time, error) {
+	raw := values.Get(name)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range queryTimeLayouts {
+		if value, err := time.ParseInLocation(layout, raw, time.Local); err == nil {
+			return value, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid %s: %s", name, raw)
+}
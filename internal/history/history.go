@@ -0,0 +1,270 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 消息方向
+const (
+	DirectionInbound  = "inbound"  // OneBot → GRUniChat
+	DirectionOutbound = "outbound" // GRUniChat → OneBot
+)
+
+// 每天一个记录文件，例如 2024-01-02.jsonl
+const dayLayout = "2006-01-02"
+
+var dayFilePattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}\.jsonl$`)
+
+// 一条已转发的消息记录
+type Record struct {
+	Direction  string     `json:"direction"`
+	Type       string     `json:"type"` // chat, command, event
+	GroupID    int64      `json:"group_id,omitempty"`
+	UserID     int64      `json:"user_id,omitempty"`
+	Sender     string     `json:"sender,omitempty"`
+	ClientID   string     `json:"client_id,omitempty"`
+	TotalID    string     `json:"total_id,omitempty"`
+	MessageID  int64      `json:"message_id,omitempty"` // 仅入站消息有OneBot消息ID
+	Text       string     `json:"text"`
+	SourceTime *time.Time `json:"source_time,omitempty"` // 消息来源方给出的时间
+	Time       time.Time  `json:"time"`                  // 转发时间
+}
+
+// 查询条件，零值字段表示不限制
+type Query struct {
+	Direction string
+	GroupID   int64
+	UserID    int64
+	ClientID  string
+	Since     time.Time
+	Until     time.Time
+	Text      string // 文本或发送者包含的内容，不区分大小写
+	Limit     int
+}
+
+// 检查记录是否满足查询条件
+func (q *Query) matches(record *Record) bool {
+	if q.Direction != "" && record.Direction != q.Direction {
+		return false
+	}
+	if q.GroupID != 0 && record.GroupID != q.GroupID {
+		return false
+	}
+	if q.UserID != 0 && record.UserID != q.UserID {
+		return false
+	}
+	if q.ClientID != "" && record.ClientID != q.ClientID {
+		return false
+	}
+	if !q.Since.IsZero() && record.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && record.Time.After(q.Until) {
+		return false
+	}
+	if q.Text != "" {
+		keyword := strings.ToLower(q.Text)
+		if !strings.Contains(strings.ToLower(record.Text), keyword) && !strings.Contains(strings.ToLower(record.Sender), keyword) {
+			return false
+		}
+	}
+	return true
+}
+
+// 基于文件的消息历史存储，按天写入JSONL文件
+type Store struct {
+	dir       string
+	retention time.Duration
+	logger    *logrus.Logger
+	mu        sync.Mutex
+	file      *os.File
+	fileDay   string
+}
+
+// 创建消息历史存储，retention 小于等于0表示永久保留
+func NewStore(dir string, retention time.Duration, logger *logrus.Logger) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	return &Store{
+		dir:       dir,
+		retention: retention,
+		logger:    logger,
+	}, nil
+}
+
+// 追加一条记录
+func (s *Store) Append(record *Record) error {
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode history record: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	day := record.Time.Format(dayLayout)
+	if s.file == nil || s.fileDay != day {
+		if err := s.openDay(day); err != nil {
+			return err
+		}
+	}
+
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write history record: %w", err)
+	}
+	return nil
+}
+
+// 切换到指定日期的记录文件（调用方需持有锁）
+func (s *Store) openDay(day string) error {
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+
+	file, err := os.OpenFile(filepath.Join(s.dir, day+".jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %w", err)
+	}
+
+	s.file = file
+	s.fileDay = day
+	return nil
+}
+
+// 查询记录，按时间从新到旧返回
+func (s *Store) Query(q Query) ([]Record, error) {
+	days, err := s.listDays()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Record
+	for i := len(days) - 1; i >= 0; i-- {
+		day, _ := time.ParseInLocation(dayLayout, days[i], time.Local)
+		if !q.Since.IsZero() && day.AddDate(0, 0, 1).Before(q.Since) {
+			break // 更早的文件都在时间范围之外
+		}
+		if !q.Until.IsZero() && day.After(q.Until) {
+			continue
+		}
+
+		records, err := s.readDay(days[i])
+		if err != nil {
+			s.logger.Warnf("Failed to read history file %s: %v", days[i], err)
+			continue
+		}
+
+		for j := len(records) - 1; j >= 0; j-- {
+			if !q.matches(&records[j]) {
+				continue
+			}
+			results = append(results, records[j])
+			if q.Limit > 0 && len(results) >= q.Limit {
+				return results, nil
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// 列出所有记录文件的日期，从旧到新排序
+func (s *Store) listDays() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history directory: %w", err)
+	}
+
+	var days []string
+	for _, entry := range entries {
+		if !entry.IsDir() && dayFilePattern.MatchString(entry.Name()) {
+			days = append(days, strings.TrimSuffix(entry.Name(), ".jsonl"))
+		}
+	}
+	sort.Strings(days)
+	return days, nil
+}
+
+// 读取指定日期的全部记录
+func (s *Store) readDay(day string) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.Open(filepath.Join(s.dir, day+".jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue // 跳过损坏的行（例如写入时进程退出）
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// 删除超出保留期限的记录文件
+func (s *Store) Cleanup() {
+	if s.retention <= 0 {
+		return
+	}
+
+	days, err := s.listDays()
+	if err != nil {
+		s.logger.Errorf("Failed to clean up history: %v", err)
+		return
+	}
+
+	cutoff := time.Now().Add(-s.retention).Format(dayLayout)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, day := range days {
+		if day >= cutoff || day == s.fileDay {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, day+".jsonl")); err != nil {
+			s.logger.Warnf("Failed to remove history file %s: %v", day, err)
+			continue
+		}
+		s.logger.Debugf("Removed history file %s", day)
+	}
+}
+
+// 关闭当前记录文件
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
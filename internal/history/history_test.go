@@ -0,0 +1,191 @@
+package history
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	store, err := NewStore(t.TempDir(), 0, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// 依次追加记录，文本为给定的值，时间从 start 开始每条相隔一小时
+func appendHourly(t *testing.T, store *Store, start time.Time, texts ...string) {
+	t.Helper()
+	for i, text := range texts {
+		record := &Record{Direction: DirectionInbound, Type: "chat", GroupID: 100, Text: text, Time: start.Add(time.Duration(i) * time.Hour)}
+		if err := store.Append(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func texts(records []Record) []string {
+	result := make([]string, 0, len(records))
+	for _, record := range records {
+		result = append(result, record.Text)
+	}
+	return result
+}
+
+func equalTexts(got []Record, want ...string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range want {
+		if got[i].Text != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestStoreRollsOverDays(t *testing.T) {
+	store := newTestStore(t)
+	// 22:00 开始，第三条记录写入第二天的文件
+	start := time.Date(2024, 5, 1, 22, 0, 0, 0, time.Local)
+	appendHourly(t, store, start, "a", "b", "c")
+
+	for _, day := range []string{"2024-05-01.jsonl", "2024-05-02.jsonl"} {
+		if _, err := os.Stat(filepath.Join(store.dir, day)); err != nil {
+			t.Errorf("history file %s: %v", day, err)
+		}
+	}
+
+	records, err := store.Query(Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalTexts(records, "c", "b", "a") {
+		t.Errorf("Query() = %v, want newest first across days", texts(records))
+	}
+}
+
+func TestStoreQueryTimeRange(t *testing.T) {
+	store := newTestStore(t)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+	// 每天一条记录：5月1日到5月4日
+	for i, text := range []string{"d1", "d2", "d3", "d4"} {
+		appendHourly(t, store, start.AddDate(0, 0, i), text)
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{"since", Query{Since: start.AddDate(0, 0, 2)}, []string{"d4", "d3"}},
+		{"until", Query{Until: start.AddDate(0, 0, 1)}, []string{"d2", "d1"}},
+		{"since and until", Query{Since: start.AddDate(0, 0, 1), Until: start.AddDate(0, 0, 2)}, []string{"d3", "d2"}},
+		{"limit", Query{Limit: 2}, []string{"d4", "d3"}},
+		{"since with limit", Query{Since: start.AddDate(0, 0, 1), Limit: 1}, []string{"d4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := store.Query(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equalTexts(records, tt.want...) {
+				t.Errorf("Query() = %v, want %v", texts(records), tt.want)
+			}
+		})
+	}
+}
+
+func TestStoreQueryFilters(t *testing.T) {
+	store := newTestStore(t)
+	now := time.Now()
+	for _, record := range []*Record{
+		{Direction: DirectionInbound, GroupID: 100, UserID: 1, Sender: "Steve", Text: "hello", Time: now},
+		{Direction: DirectionInbound, GroupID: 200, UserID: 1, Sender: "Steve", Text: "other group", Time: now},
+		{Direction: DirectionOutbound, GroupID: 100, ClientID: "survival", Sender: "Alex", Text: "<Alex> HELLO", Time: now},
+	} {
+		if err := store.Append(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{"group", Query{GroupID: 100}, []string{"<Alex> HELLO", "hello"}},
+		{"user", Query{GroupID: 100, UserID: 1}, []string{"hello"}},
+		{"direction", Query{Direction: DirectionOutbound}, []string{"<Alex> HELLO"}},
+		{"client", Query{ClientID: "survival"}, []string{"<Alex> HELLO"}},
+		{"text ignores case", Query{Text: "hello"}, []string{"<Alex> HELLO", "hello"}},
+		{"text matches sender", Query{Text: "steve"}, []string{"other group", "hello"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := store.Query(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equalTexts(records, tt.want...) {
+				t.Errorf("Query() = %v, want %v", texts(records), tt.want)
+			}
+		})
+	}
+}
+
+func TestStoreSkipsCorruptLines(t *testing.T) {
+	store := newTestStore(t)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+	appendHourly(t, store, start, "before")
+
+	// 模拟写入一半时进程退出留下的损坏行
+	file, err := os.OpenFile(filepath.Join(store.dir, "2024-05-01.jsonl"), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"direction":"inbound","text":"trunc` + "\n")
+	file.Close()
+	appendHourly(t, store, start.Add(time.Hour), "after")
+
+	records, err := store.Query(Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalTexts(records, "after", "before") {
+		t.Errorf("Query() = %v, want the corrupt line skipped", texts(records))
+	}
+}
+
+func TestStoreCleanupKeepsRetention(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	store, err := NewStore(t.TempDir(), 48*time.Hour, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	now := time.Now()
+	appendHourly(t, store, now.AddDate(0, 0, -10), "old")
+	appendHourly(t, store, now, "new")
+	store.Cleanup()
+
+	records, err := store.Query(Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalTexts(records, "new") {
+		t.Errorf("Query() after Cleanup() = %v, want only the recent record", texts(records))
+	}
+}
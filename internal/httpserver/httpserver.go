@@ -0,0 +1,99 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"grunichat-onebot-adapter/internal/config"
+)
+
+// 适配器内置的HTTP服务器，各模块在启动前注册路由
+type Server struct {
+	config *config.Config
+	logger *logrus.Logger
+	mux    *http.ServeMux
+	server *http.Server
+}
+
+// 创建HTTP服务器
+func NewServer(cfg *config.Config, logger *logrus.Logger) *Server {
+	return &Server{
+		config: cfg,
+		logger: logger,
+		mux:    http.NewServeMux(),
+	}
+}
+
+// 注册路由
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// 注册路由处理函数
+func (s *Server) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	s.mux.HandleFunc(pattern, handler)
+}
+
+// 注册管理接口路由，请求需携带 Authorization: Bearer <http.admin_token>
+func (s *Server) HandleAdmin(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, s.requireAdminToken(handler))
+}
+
+// 校验管理令牌，未配置令牌时拒绝所有请求
+func (s *Server) requireAdminToken(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		token := s.config.HTTP.AdminToken
+		if token == "" {
+			http.Error(w, "admin API disabled: http.admin_token is not set", http.StatusForbidden)
+			return
+		}
+
+		provided := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			s.logger.Warnf("Rejected admin request to %s from %s", req.URL.Path, req.RemoteAddr)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		handler.ServeHTTP(w, req)
+	})
+}
+
+// 开始监听，监听失败时返回错误
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.config.HTTP.Listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.config.HTTP.Listen, err)
+	}
+
+	s.server = &http.Server{
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	s.logger.Infof("HTTP server listening on %s", listener.Addr())
+
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Errorf("HTTP server error: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// 关闭HTTP服务器
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.server == nil {
+		return nil
+	}
+	return s.server.Shutdown(ctx)
+}
@@ -0,0 +1,61 @@
+package i18n
+
+// 消息键
+const (
+	KeyPermissionDenied        = "permission.denied"
+	KeyConfirmationPrompt      = "confirmation.prompt"
+	KeyConfirmationCancelled   = "confirmation.cancelled"
+	KeyConfirmationExpired     = "confirmation.expired"
+	KeyConfirmationConfirmed   = "confirmation.confirmed"
+	KeyConfirmationYesKeywords = "confirmation.yes_keywords"
+	KeyConfirmationNoKeywords  = "confirmation.no_keywords"
+	KeyConfirmationSender      = "confirmation.sender"
+	KeyAntiSpamMuted           = "anti_spam.muted"
+	KeyRateLimitOverflow       = "rate_limit.overflow"
+	KeyMediaImage              = "media.image"
+	KeyMediaVideo              = "media.video"
+	KeyMediaFile               = "media.file"
+	KeyHistoryHeader           = "history.header"
+	KeyHistoryEmpty            = "history.empty"
+	KeyShutdownNotice          = "shutdown.notice"
+)
+
+// 内置消息目录
+var bundledCatalogs = map[string]map[string]string{
+	"zh-CN": {
+		KeyPermissionDenied:        "权限不足，您无权执行此命令",
+		KeyConfirmationPrompt:      "@{sender} 您要执行命令：{command}\n请回复 '确认' 或 '取消'",
+		KeyConfirmationCancelled:   "命令已取消",
+		KeyConfirmationExpired:     "命令确认已超时，请重新发送命令",
+		KeyConfirmationConfirmed:   "命令已确认，正在广播到所有客户端",
+		KeyConfirmationYesKeywords: "确认,是,yes,y",
+		KeyConfirmationNoKeywords:  "取消,cancel,no",
+		KeyConfirmationSender:      "QQ用户确认执行",
+		KeyAntiSpamMuted:           "@{sender} 发言过于频繁，已被暂停转发 {minutes} 分钟",
+		KeyRateLimitOverflow:       "...以及另外 {count} 条消息",
+		KeyMediaImage:              "[图片]",
+		KeyMediaVideo:              "[视频]",
+		KeyMediaFile:               "[文件]",
+		KeyHistoryHeader:           "最近 {count} 条消息记录：",
+		KeyHistoryEmpty:            "没有找到符合条件的消息记录",
+		KeyShutdownNotice:          "适配器正在关闭，消息转发已暂停",
+	},
+	"en-US": {
+		KeyPermissionDenied:        "Permission denied: you are not allowed to run this command",
+		KeyConfirmationPrompt:      "@{sender} You are about to run: {command}\nReply 'confirm' or 'cancel'",
+		KeyConfirmationCancelled:   "Command cancelled",
+		KeyConfirmationExpired:     "Command confirmation timed out, please send the command again",
+		KeyConfirmationConfirmed:   "Command confirmed, broadcasting to all clients",
+		KeyConfirmationYesKeywords: "confirm,yes,y",
+		KeyConfirmationNoKeywords:  "cancel,no,n",
+		KeyConfirmationSender:      "Confirmed by QQ user",
+		KeyAntiSpamMuted:           "@{sender} You are sending messages too fast; forwarding paused for {minutes} minutes",
+		KeyRateLimitOverflow:       "...and {count} more events",
+		KeyMediaImage:              "[Image]",
+		KeyMediaVideo:              "[Video]",
+		KeyMediaFile:               "[File]",
+		KeyHistoryHeader:           "Last {count} messages:",
+		KeyHistoryEmpty:            "No matching messages found",
+		KeyShutdownNotice:          "The adapter is shutting down, message forwarding is paused",
+	},
+}
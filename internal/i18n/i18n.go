@@ -0,0 +1,102 @@
+package i18n
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"grunichat-onebot-adapter/internal/config"
+)
+
+// 内置的默认语言
+const FallbackLocale = "zh-CN"
+
+// 模板变量，格式为 {name}
+var templateVariablePattern = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// 消息目录，按群选择语言
+type Catalog struct {
+	config   *config.Config
+	logger   *logrus.Logger
+	messages map[string]map[string]string // locale -> key -> text
+}
+
+// 创建消息目录，配置中的覆盖条目会替换内置文本
+func NewCatalog(cfg *config.Config, logger *logrus.Logger) *Catalog {
+	messages := make(map[string]map[string]string)
+	for locale, entries := range bundledCatalogs {
+		messages[locale] = make(map[string]string, len(entries))
+		for key, text := range entries {
+			messages[locale][key] = text
+		}
+	}
+
+	for locale, entries := range cfg.I18n.Overrides {
+		if _, exists := messages[locale]; !exists {
+			messages[locale] = make(map[string]string)
+		}
+		for key, text := range entries {
+			messages[locale][key] = text
+		}
+	}
+
+	if _, exists := messages[cfg.I18n.DefaultLocale]; !exists {
+		logger.Warnf("Unknown default locale %s, falling back to %s", cfg.I18n.DefaultLocale, FallbackLocale)
+	}
+
+	return &Catalog{
+		config:   cfg,
+		logger:   logger,
+		messages: messages,
+	}
+}
+
+// 获取群使用的语言
+func (c *Catalog) Locale(groupID int64) string {
+	if locale, exists := c.config.I18n.GroupLocales[groupID]; exists {
+		return locale
+	}
+	return c.config.I18n.DefaultLocale
+}
+
+// 替换模板中 {name} 形式的变量，未知变量保持原样
+// 只扫描一遍模板，变量值中的 {name} 不会被再次展开
+func Render(template string, vars map[string]string) string {
+	return templateVariablePattern.ReplaceAllStringFunc(template, func(match string) string {
+		name := match[1 : len(match)-1]
+		if value, exists := vars[name]; exists {
+			return value
+		}
+		return match
+	})
+}
+
+// 获取指定群语言的文本，并替换 {name} 形式的变量
+func (c *Catalog) Text(groupID int64, key string, vars map[string]string) string {
+	return Render(c.lookup(c.Locale(groupID), key), vars)
+}
+
+// 获取以逗号分隔的关键词列表（不区分大小写）
+func (c *Catalog) Keywords(groupID int64, key string) []string {
+	var keywords []string
+	for _, keyword := range strings.Split(c.lookup(c.Locale(groupID), key), ",") {
+		keyword = strings.TrimSpace(strings.ToLower(keyword))
+		if keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+	return keywords
+}
+
+// 按 群语言 → 默认语言 → 内置默认语言 的顺序查找文本，都不存在时返回键名
+func (c *Catalog) lookup(locale, key string) string {
+	for _, candidate := range []string{locale, c.config.I18n.DefaultLocale, FallbackLocale} {
+		if text, exists := c.messages[candidate][key]; exists {
+			return text
+		}
+	}
+
+	c.logger.Warnf("Missing i18n entry %s for locale %s", key, locale)
+	return key
+}
@@ -0,0 +1,95 @@
+package i18n
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"grunichat-onebot-adapter/internal/config"
+)
+
+func newTestCatalog(cfg *config.Config) *Catalog {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewCatalog(cfg, logger)
+}
+
+func TestBundledCatalogsHaveSameKeys(t *testing.T) {
+	fallback := bundledCatalogs[FallbackLocale]
+	for locale, entries := range bundledCatalogs {
+		for key := range fallback {
+			if _, exists := entries[key]; !exists {
+				t.Errorf("%s is missing %s", locale, key)
+			}
+		}
+		for key := range entries {
+			if _, exists := fallback[key]; !exists {
+				t.Errorf("%s has %s which %s lacks", locale, key, FallbackLocale)
+			}
+		}
+	}
+}
+
+func TestTextSelectsGroupLocale(t *testing.T) {
+	cfg := config.Default()
+	cfg.I18n.GroupLocales = map[int64]string{200: "en-US"}
+	catalog := newTestCatalog(cfg)
+
+	vars := map[string]string{"count": "3"}
+	if got, want := catalog.Text(100, KeyRateLimitOverflow, vars), "...以及另外 3 条消息"; got != want {
+		t.Errorf("Text(100) = %q, want %q", got, want)
+	}
+	if got, want := catalog.Text(200, KeyRateLimitOverflow, vars), "...and 3 more events"; got != want {
+		t.Errorf("Text(200) = %q, want %q", got, want)
+	}
+}
+
+func TestTextDoesNotExpandVariableValues(t *testing.T) {
+	catalog := newTestCatalog(config.Default())
+
+	// 发送者名称中的 {command} 必须原样保留，不能被替换为命令内容
+	got := catalog.Text(100, KeyConfirmationPrompt, map[string]string{"sender": "{command}", "command": "stop"})
+	if want := "@{command} 您要执行命令：stop\n请回复 '确认' 或 '取消'"; got != want {
+		t.Errorf("Text() = %q, want %q", got, want)
+	}
+}
+
+func TestTextFallbackOrder(t *testing.T) {
+	cfg := config.Default()
+	cfg.I18n.DefaultLocale = "en-US"
+	cfg.I18n.GroupLocales = map[int64]string{200: "ja-JP"}
+	cfg.I18n.Overrides = map[string]map[string]string{
+		"ja-JP": {KeyMediaImage: "[画像]"},
+		"en-US": {KeyMediaVideo: "[Clip]"},
+	}
+	catalog := newTestCatalog(cfg)
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{KeyMediaImage, "[画像]"},   // 群语言的覆盖条目
+		{KeyMediaVideo, "[Clip]"}, // 群语言缺失时使用默认语言
+		{KeyMediaFile, "[File]"},
+		{"missing.key", "missing.key"},
+	}
+	for _, tt := range tests {
+		if got := catalog.Text(200, tt.key, nil); got != tt.want {
+			t.Errorf("Text(200, %q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestKeywords(t *testing.T) {
+	cfg := config.Default()
+	cfg.I18n.Overrides = map[string]map[string]string{
+		"zh-CN": {KeyConfirmationYesKeywords: " 确认, OK ,,Yes"},
+	}
+	catalog := newTestCatalog(cfg)
+
+	if got, want := catalog.Keywords(100, KeyConfirmationYesKeywords), []string{"确认", "ok", "yes"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keywords() = %v, want %v", got, want)
+	}
+}
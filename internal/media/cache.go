@@ -0,0 +1,148 @@
+package media
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 缓存文件名：sha256 + 可选扩展名
+var cacheNamePattern = regexp.MustCompile(`^[0-9a-f]{64}(\.[0-9a-z]{1,8})?$`)
+
+// 按内容寻址的本地媒体缓存
+type Cache struct {
+	dir      string
+	maxBytes int64
+	ttl      time.Duration
+	logger   *logrus.Logger
+	mu       sync.Mutex
+}
+
+// 创建媒体缓存
+func NewCache(dir string, maxBytes int64, ttl time.Duration, logger *logrus.Logger) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create media cache directory: %w", err)
+	}
+
+	return &Cache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		logger:   logger,
+	}, nil
+}
+
+// 存储文件内容，返回缓存文件名；相同内容只保存一份
+func (c *Cache) Put(data []byte, ext string) (string, error) {
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:]) + ext
+	path := filepath.Join(c.dir, name)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// 已存在时刷新修改时间，延长有效期
+	if _, err := os.Stat(path); err == nil {
+		now := time.Now()
+		if err := os.Chtimes(path, now, now); err != nil {
+			c.logger.Warnf("Failed to refresh media cache entry %s: %v", name, err)
+		}
+		return name, nil
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write media cache entry: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to store media cache entry: %w", err)
+	}
+
+	return name, nil
+}
+
+// 获取缓存文件路径，文件名无效或不存在时返回false
+func (c *Cache) Path(name string) (string, bool) {
+	if !cacheNamePattern.MatchString(name) {
+		return "", false
+	}
+
+	path := filepath.Join(c.dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// 清理过期文件，并在超出容量时按修改时间从旧到新删除
+func (c *Cache) Cleanup() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		c.logger.Errorf("Failed to read media cache directory: %v", err)
+		return
+	}
+
+	type cacheFile struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+
+	var files []cacheFile
+	var total int64
+	now := time.Now()
+	for _, entry := range entries {
+		if entry.IsDir() || !cacheNamePattern.MatchString(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		path := filepath.Join(c.dir, entry.Name())
+		if c.ttl > 0 && now.Sub(info.ModTime()) > c.ttl {
+			c.remove(path)
+			continue
+		}
+
+		files = append(files, cacheFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+	}
+
+	if c.maxBytes <= 0 || total <= c.maxBytes {
+		return
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+	for _, file := range files {
+		if total <= c.maxBytes {
+			break
+		}
+		c.remove(file.path)
+		total -= file.size
+	}
+}
+
+// 删除缓存文件（调用方需持有锁）
+func (c *Cache) remove(path string) {
+	if err := os.Remove(path); err != nil {
+		c.logger.Warnf("Failed to remove media cache entry %s: %v", path, err)
+		return
+	}
+	c.logger.Debugf("Removed media cache entry %s", filepath.Base(path))
+}
@@ -0,0 +1,319 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"grunichat-onebot-adapter/internal/config"
+	"grunichat-onebot-adapter/internal/sender"
+)
+
+// 支持转发的媒体消息段类型
+var supportedSegmentTypes = map[string]bool{
+	"image": true,
+	"video": true,
+	"file":  true,
+}
+
+// 允许用作缓存文件名的扩展名及其内容类型，其他文件缓存时不带扩展名，下载时按附件处理
+// 不包含 .html、.svg 等浏览器会执行脚本的类型，缓存文件与管理接口同源
+var mediaTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".bmp":  "image/bmp",
+	".mp3":  "audio/mpeg",
+	".wav":  "audio/wav",
+	".ogg":  "audio/ogg",
+	".m4a":  "audio/mp4",
+	".aac":  "audio/aac",
+	".flac": "audio/flac",
+	".amr":  "audio/amr",
+	".mp4":  "video/mp4",
+	".webm": "video/webm",
+	".mov":  "video/quicktime",
+	".mkv":  "video/x-matroska",
+	".avi":  "video/x-msvideo",
+}
+
+// 已转发的媒体文件
+type Item struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+	URL  string `json:"url"`
+	Size int64  `json:"size,omitempty"`
+}
+
+// 媒体转发器：获取OneBot消息段中的媒体文件，存入本地缓存并生成可访问的链接
+type Relay struct {
+	config *config.Config
+	logger *logrus.Logger
+	cache  *Cache
+	api    sender.IActionCaller
+	client *http.Client
+	// 单次下载的超时时间，短于 message_timeout，避免一个慢速链接占满整条消息的处理时间
+	fetchTimeout time.Duration
+}
+
+// 创建媒体转发器
+func NewRelay(cfg *config.Config, logger *logrus.Logger, api sender.IActionCaller) (*Relay, error) {
+	cache, err := NewCache(
+		cfg.Media.CacheDir,
+		int64(cfg.Media.MaxCacheMB)*1024*1024,
+		time.Duration(cfg.Media.TTLHours)*time.Hour,
+		logger,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	fetchTimeout := time.Duration(cfg.Media.FetchTimeout) * time.Second
+	if messageTimeout := time.Duration(cfg.Performance.MessageTimeout) * time.Second; fetchTimeout >= messageTimeout {
+		fetchTimeout = messageTimeout / 2
+	}
+
+	return &Relay{
+		config:       cfg,
+		logger:       logger,
+		cache:        cache,
+		api:          api,
+		client:       &http.Client{Timeout: fetchTimeout},
+		fetchTimeout: fetchTimeout,
+	}, nil
+}
+
+// 检查消息段类型是否支持转发
+func IsMediaSegment(segmentType string) bool {
+	return supportedSegmentTypes[segmentType]
+}
+
+// 解析媒体消息段，返回转发后的媒体信息
+func (r *Relay) Resolve(ctx context.Context, segmentType string, data map[string]interface{}) (*Item, error) {
+	sourceURL, _ := data["url"].(string)
+	fileID, _ := data["file"].(string)
+	name, _ := data["name"].(string)
+	if name == "" {
+		name = path.Base(fileID)
+	}
+
+	var content []byte
+	var contentType string
+	var err error
+
+	switch {
+	case sourceURL != "":
+		content, contentType, err = r.fetch(ctx, sourceURL)
+	case segmentType == "image" && fileID != "":
+		// 没有URL时通过 get_image 获取图片
+		content, contentType, sourceURL, err = r.fetchImageByFile(ctx, fileID)
+	default:
+		return nil, fmt.Errorf("%s segment has no url", segmentType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cacheName, err := r.cache.Put(content, fileExtension(name, sourceURL, contentType))
+	if err != nil {
+		// 缓存失败时退回使用原始链接
+		r.logger.Warnf("Failed to cache %s, using original url: %v", segmentType, err)
+		return &Item{Type: segmentType, Name: name, URL: sourceURL, Size: int64(len(content))}, nil
+	}
+
+	return &Item{
+		Type: segmentType,
+		Name: name,
+		URL:  r.publicURL(cacheName, sourceURL),
+		Size: int64(len(content)),
+	}, nil
+}
+
+// 生成缓存文件的访问链接，未启用HTTP服务时使用原始链接
+func (r *Relay) publicURL(cacheName, sourceURL string) string {
+	if !r.config.HTTP.Enabled {
+		return sourceURL
+	}
+	return strings.TrimRight(r.config.HTTP.PublicURL, "/") + "/media/" + cacheName
+}
+
+// 下载媒体文件
+func (r *Relay) fetch(ctx context.Context, url string) ([]byte, string, error) {
+	if strings.HasPrefix(url, "file://") {
+		return r.readLocalFile(strings.TrimPrefix(url, "file://"))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.fetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid media url: %w", err)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download media: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("failed to download media: status %d", resp.StatusCode)
+	}
+
+	content, err := r.readLimited(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return content, resp.Header.Get("Content-Type"), nil
+}
+
+// 通过 get_image 获取图片，返回内容、类型和原始链接
+func (r *Relay) fetchImageByFile(ctx context.Context, fileID string) ([]byte, string, string, error) {
+	callCtx, cancel := context.WithTimeout(ctx, r.fetchTimeout)
+	response, err := r.api.CallAction(callCtx, "get_image", map[string]interface{}{"file": fileID})
+	cancel()
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	data, _ := response.Data.(map[string]interface{})
+	if url, _ := data["url"].(string); url != "" {
+		content, contentType, err := r.fetch(ctx, url)
+		return content, contentType, url, err
+	}
+	if localPath, _ := data["file"].(string); localPath != "" {
+		content, contentType, err := r.readLocalFile(localPath)
+		return content, contentType, "", err
+	}
+
+	return nil, "", "", fmt.Errorf("get_image returned neither url nor file")
+}
+
+// 读取OneBot实现所在机器上的本地文件（仅在同一台机器上部署时可用）
+func (r *Relay) readLocalFile(localPath string) ([]byte, string, error) {
+	resolved, err := r.resolveLocalPath(localPath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	file, err := os.Open(resolved)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open media file: %w", err)
+	}
+	defer file.Close()
+
+	content, err := r.readLimited(file)
+	if err != nil {
+		return nil, "", err
+	}
+	return content, mime.TypeByExtension(filepath.Ext(localPath)), nil
+}
+
+// 检查本地文件是否允许读取，返回解析符号链接后的绝对路径
+func (r *Relay) resolveLocalPath(localPath string) (string, error) {
+	cfg := r.config.Media
+	if !cfg.AllowLocalFiles {
+		return "", fmt.Errorf("reading local media files is disabled")
+	}
+	if cfg.LocalFileDir == "" {
+		return "", fmt.Errorf("media.local_file_dir is not set")
+	}
+
+	baseDir, err := filepath.Abs(cfg.LocalFileDir)
+	if err == nil {
+		baseDir, err = filepath.EvalSymlinks(baseDir)
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid media.local_file_dir: %w", err)
+	}
+
+	resolved, err := filepath.Abs(filepath.Clean(localPath))
+	if err == nil {
+		resolved, err = filepath.EvalSymlinks(resolved)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve media file: %w", err)
+	}
+
+	rel, err := filepath.Rel(baseDir, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("media file %s is outside %s", localPath, cfg.LocalFileDir)
+	}
+	return resolved, nil
+}
+
+// 读取内容，超过单个文件大小限制时返回错误
+func (r *Relay) readLimited(reader io.Reader) ([]byte, error) {
+	maxBytes := int64(r.config.Media.MaxFileMB) * 1024 * 1024
+	content, err := io.ReadAll(io.LimitReader(reader, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read media: %w", err)
+	}
+	if int64(len(content)) > maxBytes {
+		return nil, fmt.Errorf("media exceeds size limit of %d MB", r.config.Media.MaxFileMB)
+	}
+	return content, nil
+}
+
+// 推断文件扩展名，只返回允许的媒体扩展名，无法推断时返回空字符串
+func fileExtension(name, url, contentType string) string {
+	for _, candidate := range []string{name, strings.SplitN(url, "?", 2)[0]} {
+		ext := strings.ToLower(path.Ext(candidate))
+		if _, allowed := mediaTypes[ext]; allowed {
+			return ext
+		}
+	}
+
+	if contentType != "" {
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		exts, _ := mime.ExtensionsByType(mediaType)
+		for _, ext := range exts {
+			if mediaTypes[ext] == mediaType {
+				return ext
+			}
+		}
+	}
+	return ""
+}
+
+// 清理过期的缓存文件
+func (r *Relay) Cleanup() {
+	r.cache.Cleanup()
+}
+
+// 媒体文件HTTP处理器，路由为 /media/<缓存文件名>
+func (r *Relay) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		name := strings.TrimPrefix(req.URL.Path, "/media/")
+		filePath, ok := r.cache.Path(name)
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+
+		// 显式设置内容类型并禁止浏览器嗅探，图片以外的文件一律作为附件下载
+		contentType, allowed := mediaTypes[path.Ext(name)]
+		if !allowed {
+			contentType = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if !strings.HasPrefix(contentType, "image/") {
+			w.Header().Set("Content-Disposition", "attachment")
+		}
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		http.ServeFile(w, req, filePath)
+	})
+}
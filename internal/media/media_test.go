@@ -0,0 +1,202 @@
+package media
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"grunichat-onebot-adapter/internal/config"
+)
+
+// 启用媒体转发，缓存目录位于测试临时目录下
+func relayConfig(t *testing.T) *config.Config {
+	cfg := config.Default()
+	cfg.Media.Enabled = true
+	cfg.Media.CacheDir = filepath.Join(t.TempDir(), "cache")
+	return cfg
+}
+
+func newTestRelay(t *testing.T, cfg *config.Config) *Relay {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	relay, err := NewRelay(cfg, logger, nil)
+	if err != nil {
+		t.Fatalf("NewRelay() error = %v", err)
+	}
+	return relay
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolveRejectsLocalFilesByDefault(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "secret.txt")
+	writeFile(t, secret, "secret")
+	relay := newTestRelay(t, relayConfig(t))
+
+	if _, err := relay.Resolve(context.Background(), "image", map[string]interface{}{"url": "file://" + secret}); err == nil {
+		t.Fatal("Resolve() read a local file while allow_local_files is off")
+	}
+}
+
+func TestResolveLocalFilesUnderBaseDir(t *testing.T) {
+	baseDir := t.TempDir()
+	outsideDir := t.TempDir()
+	image := filepath.Join(baseDir, "a.png")
+	secret := filepath.Join(outsideDir, "secret.txt")
+	writeFile(t, image, "png")
+	writeFile(t, secret, "secret")
+	if err := os.Symlink(secret, filepath.Join(baseDir, "link.png")); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := relayConfig(t)
+	cfg.Media.AllowLocalFiles = true
+	cfg.Media.LocalFileDir = baseDir
+	relay := newTestRelay(t, cfg)
+
+	item, err := relay.Resolve(context.Background(), "image", map[string]interface{}{"url": "file://" + image})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if item.Size != 3 {
+		t.Errorf("item.Size = %d, want 3", item.Size)
+	}
+
+	for _, path := range []string{
+		secret,
+		filepath.Join(baseDir, "..", filepath.Base(outsideDir), "secret.txt"),
+		filepath.Join(baseDir, "link.png"),
+	} {
+		if _, err := relay.Resolve(context.Background(), "image", map[string]interface{}{"url": "file://" + path}); err == nil {
+			t.Errorf("Resolve() read %s outside local_file_dir", path)
+		}
+	}
+}
+
+func TestResolveLocalFilesRequiresBaseDir(t *testing.T) {
+	image := filepath.Join(t.TempDir(), "a.png")
+	writeFile(t, image, "png")
+	cfg := relayConfig(t)
+	cfg.Media.AllowLocalFiles = true
+	relay := newTestRelay(t, cfg)
+
+	if _, err := relay.Resolve(context.Background(), "image", map[string]interface{}{"url": "file://" + image}); err == nil {
+		t.Fatal("Resolve() read a local file without local_file_dir")
+	}
+}
+
+func TestFileExtension(t *testing.T) {
+	tests := []struct {
+		name        string
+		fileName    string
+		url         string
+		contentType string
+		want        string
+	}{
+		{"name", "A.PNG", "", "", ".png"},
+		{"url without query", "", "http://example.com/v.mp4?x=.html", "", ".mp4"},
+		{"content type", "", "http://example.com/x", "image/gif; charset=binary", ".gif"},
+		{"html", "page.html", "http://example.com/page.html", "text/html", ""},
+		{"svg", "icon.svg", "", "image/svg+xml", ""},
+		{"unknown", "archive.zip", "", "application/zip", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fileExtension(tt.fileName, tt.url, tt.contentType); got != tt.want {
+				t.Errorf("fileExtension(%q, %q, %q) = %q, want %q", tt.fileName, tt.url, tt.contentType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerServesNonImagesAsAttachments(t *testing.T) {
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.HasSuffix(req.URL.Path, ".png") {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("\x89PNG"))
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<script>alert(1)</script>"))
+	}))
+	defer source.Close()
+
+	cfg := relayConfig(t)
+	cfg.HTTP.Enabled = true
+	cfg.HTTP.PublicURL = "http://adapter"
+	relay := newTestRelay(t, cfg)
+	server := httptest.NewServer(relay.Handler())
+	defer server.Close()
+
+	tests := []struct {
+		segmentType     string
+		url             string
+		wantType        string
+		wantDisposition string
+	}{
+		{"image", source.URL + "/a.png", "image/png", ""},
+		{"file", source.URL + "/page.html", "application/octet-stream", "attachment"},
+		{"image", source.URL + "/icon.svg", "application/octet-stream", "attachment"},
+	}
+	for _, tt := range tests {
+		item, err := relay.Resolve(context.Background(), tt.segmentType, map[string]interface{}{"url": tt.url})
+		if err != nil {
+			t.Fatalf("Resolve(%s) error = %v", tt.url, err)
+		}
+
+		resp, err := http.Get(server.URL + strings.TrimPrefix(item.URL, "http://adapter"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s status = %d", item.URL, resp.StatusCode)
+		}
+		if got := resp.Header.Get("Content-Type"); got != tt.wantType {
+			t.Errorf("%s: Content-Type = %q, want %q", tt.url, got, tt.wantType)
+		}
+		if got := resp.Header.Get("Content-Disposition"); got != tt.wantDisposition {
+			t.Errorf("%s: Content-Disposition = %q, want %q", tt.url, got, tt.wantDisposition)
+		}
+		if got := resp.Header.Get("X-Content-Type-Options"); got != "nosniff" {
+			t.Errorf("%s: X-Content-Type-Options = %q, want nosniff", tt.url, got)
+		}
+	}
+}
+
+func TestResolveFetchTimeoutIsShorterThanMessageTimeout(t *testing.T) {
+	release := make(chan struct{})
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-release
+	}))
+	defer source.Close()
+	defer close(release)
+
+	cfg := relayConfig(t)
+	cfg.Performance.MessageTimeout = 1
+	cfg.Media.FetchTimeout = 5
+	relay := newTestRelay(t, cfg)
+
+	start := time.Now()
+	if _, err := relay.Resolve(context.Background(), "image", map[string]interface{}{"url": source.URL + "/a.png"}); err == nil {
+		t.Fatal("Resolve() succeeded against a stalled server")
+	}
+	// fetch_timeout 不小于 message_timeout 时取其一半
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("Resolve() took %v, want less than message_timeout", elapsed)
+	}
+}
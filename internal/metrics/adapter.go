@@ -0,0 +1,55 @@
+package metrics
+
+// 适配器的全局指标注册表
+var Default = NewRegistry()
+
+// 适配器指标
+var (
+	MessagesTotal = NewCounterVec(
+		"grunichat_adapter_messages_total",
+		"Messages bridged between OneBot and GRUniChat.",
+		"direction", "group_id", "client_id",
+	)
+	FilteredMessagesTotal = NewCounterVec(
+		"grunichat_adapter_filtered_messages_total",
+		"Messages dropped by filters, by reason.",
+		"direction", "reason",
+	)
+	SendFailuresTotal = NewCounterVec(
+		"grunichat_adapter_send_failures_total",
+		"Messages that could not be sent, by target connection.",
+		"target",
+	)
+	ReconnectAttemptsTotal = NewCounterVec(
+		"grunichat_adapter_reconnect_attempts_total",
+		"Connection attempts after the first one, by connection.",
+		"connection",
+	)
+	PendingConfirmations = NewGaugeVec(
+		"grunichat_adapter_pending_confirmations",
+		"Commands waiting for confirmation.",
+	)
+	QueueDepth = NewGaugeVec(
+		"grunichat_adapter_queue_depth",
+		"Messages waiting in internal queues.",
+		"queue",
+	)
+	StageDuration = NewHistogramVec(
+		"grunichat_adapter_stage_duration_seconds",
+		"Time spent in each middleware stage, excluding later stages.",
+		nil,
+		"direction", "stage",
+	)
+)
+
+func init() {
+	Default.MustRegister(
+		MessagesTotal,
+		FilteredMessagesTotal,
+		SendFailuresTotal,
+		ReconnectAttemptsTotal,
+		PendingConfirmations,
+		QueueDepth,
+		StageDuration,
+	)
+}
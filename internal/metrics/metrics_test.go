@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistryWritesPrometheusText(t *testing.T) {
+	registry := NewRegistry()
+	counter := NewCounterVec("test_messages_total", "Messages.", "direction")
+	gauge := NewGaugeVec("test_queue_depth", "Queue depth.", "queue")
+	histogram := NewHistogramVec("test_duration_seconds", "Duration.", []float64{0.1, 1}, "stage")
+	registry.MustRegister(counter, gauge, histogram)
+
+	counter.Inc("inbound")
+	counter.Add(2, "inbound")
+	gauge.Set(3, `a"b`)
+	histogram.Observe(0.5, "filter")
+
+	var out strings.Builder
+	if err := registry.Write(&out); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	for _, line := range []string{
+		"# TYPE test_messages_total counter",
+		`test_messages_total{direction="inbound"} 3`,
+		`test_queue_depth{queue="a\"b"} 3`,
+		`test_duration_seconds_bucket{stage="filter",le="0.1"} 0`,
+		`test_duration_seconds_bucket{stage="filter",le="1"} 1`,
+		`test_duration_seconds_count{stage="filter"} 1`,
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("output is missing %q:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestRegistryOnCollectUnregister(t *testing.T) {
+	registry := NewRegistry()
+	calls := 0
+	unregister := registry.OnCollect(func() { calls++ })
+
+	registry.Write(&strings.Builder{})
+	unregister()
+	registry.Write(&strings.Builder{})
+	unregister() // 重复取消注册不应出错
+
+	if calls != 1 {
+		t.Fatalf("hook called %d times, want 1", calls)
+	}
+}
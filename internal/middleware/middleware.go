@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"grunichat-onebot-adapter/internal/types"
+)
+
+// 消息方向
+const (
+	DirectionInbound  = "inbound"  // OneBot → GRUniChat
+	DirectionOutbound = "outbound" // GRUniChat → OneBot
+)
+
+// 在处理阶段之间传递的消息信封
+type Envelope struct {
+	Direction  string
+	OneBot     *types.OneBotMessage    // 入站时为原始OneBot消息
+	GRUniChat  *types.GRUniChatMessage // 入站时为构建中的GRUniChat消息，出站时为原始GRUniChat消息
+	GroupID    int64                   // 入站时为来源群，出站时为目标群
+	SenderName string                  // 入站时为发送者显示名称
+	Text       string                  // 当前处理中的文本
+	Values     map[string]interface{}  // 自定义阶段之间共享的数据
+}
+
+// 创建消息信封
+func NewEnvelope(direction string) *Envelope {
+	return &Envelope{
+		Direction: direction,
+		Values:    make(map[string]interface{}),
+	}
+}
+
+// 消息处理函数
+type Handler func(ctx context.Context, env *Envelope) error
+
+// 中间件，不调用next即表示终止处理（如消息被过滤）
+type Middleware func(ctx context.Context, env *Envelope, next Handler) error
+
+// 中间件链
+type Chain struct {
+	middlewares []Middleware
+}
+
+// 创建中间件链
+func NewChain(middlewares ...Middleware) *Chain {
+	return &Chain{middlewares: middlewares}
+}
+
+// 追加中间件
+func (c *Chain) Use(mw Middleware) {
+	c.middlewares = append(c.middlewares, mw)
+}
+
+// 组合中间件链与最终处理函数
+func (c *Chain) Then(final Handler) Handler {
+	handler := final
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		mw := c.middlewares[i]
+		next := handler
+		handler = func(ctx context.Context, env *Envelope) error {
+			return mw(ctx, env, next)
+		}
+	}
+	return handler
+}
+
+// 中间件注册表，按名称查找阶段
+type Registry struct {
+	mu     sync.RWMutex
+	stages map[string]Middleware
+}
+
+// 创建中间件注册表
+func NewRegistry() *Registry {
+	return &Registry{stages: make(map[string]Middleware)}
+}
+
+// 注册中间件，同名注册会覆盖之前的阶段
+func (r *Registry) Register(name string, mw Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stages[name] = mw
+}
+
+// 查找中间件
+func (r *Registry) Get(name string) (Middleware, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	mw, exists := r.stages[name]
+	return mw, exists
+}
+
+// 按名称顺序构建中间件链，返回的错误包含所有未知的阶段名称
+func (r *Registry) Build(names []string) (*Chain, error) {
+	return r.BuildWrapped(names, nil)
+}
+
+// 按名称顺序构建中间件链，每个阶段先经过 wrap 包装（例如统计耗时），wrap 为nil时不包装
+func (r *Registry) BuildWrapped(names []string, wrap func(name string, mw Middleware) Middleware) (*Chain, error) {
+	chain := NewChain()
+	var unknown []string
+	for _, name := range names {
+		mw, exists := r.Get(name)
+		if !exists {
+			unknown = append(unknown, name)
+			continue
+		}
+		if wrap != nil {
+			mw = wrap(name, mw)
+		}
+		chain.Use(mw)
+	}
+	if len(unknown) > 0 {
+		return chain, fmt.Errorf("unknown middleware stages: %v", unknown)
+	}
+	return chain, nil
+}
+
+// 全局注册表，供外部程序在创建适配器前注册自定义阶段
+// 本包位于 internal 下，模块外的程序需通过 pkg/bridge 的 RegisterStage 注册
+var defaultRegistry = NewRegistry()
+
+// 在全局注册表中注册中间件
+func Register(name string, mw Middleware) {
+	defaultRegistry.Register(name, mw)
+}
+
+// 将全局注册表中的阶段复制到指定注册表
+func CopyRegistered(dst *Registry) {
+	defaultRegistry.mu.RLock()
+	defer defaultRegistry.mu.RUnlock()
+	for name, mw := range defaultRegistry.stages {
+		dst.Register(name, mw)
+	}
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// 记录调用顺序后继续处理的阶段
+func recordStage(name string, calls *[]string) Middleware {
+	return func(ctx context.Context, env *Envelope, next Handler) error {
+		*calls = append(*calls, name)
+		return next(ctx, env)
+	}
+}
+
+// 记录调用顺序的最终处理函数
+func recordFinal(calls *[]string) Handler {
+	return func(ctx context.Context, env *Envelope) error {
+		*calls = append(*calls, "final")
+		return nil
+	}
+}
+
+func TestChainRunsInOrder(t *testing.T) {
+	var calls []string
+	chain := NewChain(recordStage("a", &calls), recordStage("b", &calls))
+	chain.Use(recordStage("c", &calls))
+
+	if err := chain.Then(recordFinal(&calls))(context.Background(), NewEnvelope(DirectionInbound)); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(calls, ","); got != "a,b,c,final" {
+		t.Errorf("calls = %s, want a,b,c,final", got)
+	}
+}
+
+func TestChainStopsWhenNextIsNotCalled(t *testing.T) {
+	var calls []string
+	stop := func(ctx context.Context, env *Envelope, next Handler) error {
+		calls = append(calls, "stop")
+		return nil
+	}
+	chain := NewChain(recordStage("a", &calls), stop, recordStage("b", &calls))
+
+	if err := chain.Then(recordFinal(&calls))(context.Background(), NewEnvelope(DirectionInbound)); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(calls, ","); got != "a,stop" {
+		t.Errorf("calls = %s, want a,stop", got)
+	}
+}
+
+func TestChainSharesEnvelope(t *testing.T) {
+	set := func(ctx context.Context, env *Envelope, next Handler) error {
+		env.Text += "!"
+		env.Values["seen"] = true
+		return next(ctx, env)
+	}
+	env := NewEnvelope(DirectionOutbound)
+	env.Text = "hi"
+
+	var seen bool
+	err := NewChain(set, set).Then(func(ctx context.Context, env *Envelope) error {
+		seen, _ = env.Values["seen"].(bool)
+		return nil
+	})(context.Background(), env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.Text != "hi!!" || !seen {
+		t.Errorf("envelope = %q seen %v, want hi!! seen true", env.Text, seen)
+	}
+}
+
+func TestRegistryBuild(t *testing.T) {
+	var calls []string
+	registry := NewRegistry()
+	registry.Register("a", recordStage("a", &calls))
+	registry.Register("b", recordStage("b", &calls))
+	// 同名注册覆盖之前的阶段
+	registry.Register("b", recordStage("b2", &calls))
+
+	chain, err := registry.Build([]string{"b", "missing", "a", "other"})
+	if err == nil || !strings.Contains(err.Error(), "[missing other]") {
+		t.Errorf("Build() error = %v, want both unknown stages reported", err)
+	}
+	if err := chain.Then(recordFinal(&calls))(context.Background(), NewEnvelope(DirectionInbound)); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(calls, ","); got != "b2,a,final" {
+		t.Errorf("calls = %s, want the known stages in configured order", got)
+	}
+}
+
+func TestRegistryBuildWrapped(t *testing.T) {
+	var calls []string
+	registry := NewRegistry()
+	registry.Register("a", recordStage("a", &calls))
+
+	chain, err := registry.BuildWrapped([]string{"a"}, func(name string, mw Middleware) Middleware {
+		return func(ctx context.Context, env *Envelope, next Handler) error {
+			calls = append(calls, "wrap:"+name)
+			return mw(ctx, env, next)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.Then(recordFinal(&calls))(context.Background(), NewEnvelope(DirectionInbound)); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(calls, ","); got != "wrap:a,a,final" {
+		t.Errorf("calls = %s, want wrap:a,a,final", got)
+	}
+}
+
+func TestRegisterCopiesGlobalStages(t *testing.T) {
+	var calls []string
+	Register("middleware_test_stage", recordStage("global", &calls))
+
+	registry := NewRegistry()
+	registry.Register("middleware_test_stage", recordStage("local", &calls))
+	CopyRegistered(registry)
+
+	// 全局注册的阶段覆盖注册表中的同名阶段
+	mw, exists := registry.Get("middleware_test_stage")
+	if !exists {
+		t.Fatal("global stage was not copied")
+	}
+	if err := NewChain(mw).Then(recordFinal(&calls))(context.Background(), NewEnvelope(DirectionInbound)); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(calls, ","); got != "global,final" {
+		t.Errorf("calls = %s, want global,final", got)
+	}
+}
@@ -0,0 +1,226 @@
+package offline
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"grunichat-onebot-adapter/internal/types"
+)
+
+// 缓存中的一条消息
+type entry struct {
+	Message    *types.GRUniChatMessage `json:"message"`
+	BufferedAt time.Time               `json:"buffered_at"`
+}
+
+// GRUniChat断开期间的消息缓存，按先进先出顺序保存
+type Buffer struct {
+	maxMessages int
+	maxAge      time.Duration
+	file        string // 持久化文件路径，为空时只保存在内存中
+	logger      *logrus.Logger
+	mu          sync.Mutex
+	entries     []entry
+}
+
+// 创建消息缓存，file 非空时从文件中恢复上次未发送的消息
+func NewBuffer(maxMessages int, maxAge time.Duration, file string, logger *logrus.Logger) (*Buffer, error) {
+	b := &Buffer{
+		maxMessages: maxMessages,
+		maxAge:      maxAge,
+		file:        file,
+		logger:      logger,
+	}
+
+	if file != "" {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create offline buffer directory: %w", err)
+		}
+		if err := b.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+// 从文件加载缓存的消息
+func (b *Buffer) load() error {
+	file, err := os.Open(b.file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open offline buffer: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var e entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || e.Message == nil {
+			continue // 跳过损坏的行
+		}
+		b.entries = append(b.entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read offline buffer: %w", err)
+	}
+
+	b.trim()
+	if len(b.entries) > 0 {
+		b.logger.Infof("Restored %d buffered GRUniChat messages from %s", len(b.entries), b.file)
+	}
+	return nil
+}
+
+// 添加消息，超出容量时丢弃最早的消息
+func (b *Buffer) Push(message *types.GRUniChatMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e := entry{Message: message, BufferedAt: time.Now()}
+	b.entries = append(b.entries, e)
+
+	if b.trim() {
+		b.persist() // 有消息被丢弃时重写整个文件
+	} else {
+		b.appendToFile(e)
+	}
+}
+
+// 按顺序重放缓存的消息，遇到发送失败时停止并保留剩余消息，返回已发送的数量
+// 发送期间不持有锁，新消息可以继续进入缓存；send 不能修改传入的消息
+func (b *Buffer) Replay(send func(message *types.GRUniChatMessage) error) (int, error) {
+	b.mu.Lock()
+	if b.trim() {
+		b.persist()
+	}
+	pending := append([]entry(nil), b.entries...)
+	b.mu.Unlock()
+
+	sent := 0
+	var err error
+	for _, e := range pending {
+		if err = send(e.Message); err != nil {
+			break
+		}
+		sent++
+	}
+	if sent == 0 {
+		return 0, err
+	}
+
+	// 发送期间缓存可能因新消息超出容量而丢弃了部分消息，按消息删除已发送的条目
+	delivered := make(map[*types.GRUniChatMessage]bool, sent)
+	for _, e := range pending[:sent] {
+		delivered[e.Message] = true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	kept := make([]entry, 0, len(b.entries))
+	for _, e := range b.entries {
+		if !delivered[e.Message] {
+			kept = append(kept, e)
+		}
+	}
+	b.entries = kept
+	b.persist()
+	return sent, err
+}
+
+// 缓存中的消息数量
+func (b *Buffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.entries)
+}
+
+// 丢弃过期和超出容量的消息，返回是否有消息被丢弃（调用方需持有锁）
+func (b *Buffer) trim() bool {
+	dropped := 0
+
+	if b.maxAge > 0 {
+		cutoff := time.Now().Add(-b.maxAge)
+		kept := b.entries[:0]
+		for _, e := range b.entries {
+			if e.BufferedAt.Before(cutoff) {
+				dropped++
+				continue
+			}
+			kept = append(kept, e)
+		}
+		b.entries = kept
+	}
+
+	if b.maxMessages > 0 && len(b.entries) > b.maxMessages {
+		overflow := len(b.entries) - b.maxMessages
+		b.entries = append([]entry(nil), b.entries[overflow:]...)
+		dropped += overflow
+	}
+
+	if dropped > 0 {
+		b.logger.Warnf("Discarded %d buffered GRUniChat messages (expired or buffer full)", dropped)
+	}
+	return dropped > 0
+}
+
+// 追加一条消息到持久化文件（调用方需持有锁）
+func (b *Buffer) appendToFile(e entry) {
+	if b.file == "" {
+		return
+	}
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		b.logger.Errorf("Failed to encode buffered message: %v", err)
+		return
+	}
+
+	file, err := os.OpenFile(b.file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		b.logger.Errorf("Failed to open offline buffer: %v", err)
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		b.logger.Errorf("Failed to write offline buffer: %v", err)
+	}
+}
+
+// 将当前缓存完整写入持久化文件（调用方需持有锁）
+func (b *Buffer) persist() {
+	if b.file == "" {
+		return
+	}
+
+	var content []byte
+	for _, e := range b.entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			continue
+		}
+		content = append(content, line...)
+		content = append(content, '\n')
+	}
+
+	tmpPath := b.file + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0644); err != nil {
+		b.logger.Errorf("Failed to write offline buffer: %v", err)
+		return
+	}
+	if err := os.Rename(tmpPath, b.file); err != nil {
+		os.Remove(tmpPath)
+		b.logger.Errorf("Failed to write offline buffer: %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package offline
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"grunichat-onebot-adapter/internal/types"
+)
+
+func testLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func pushIDs(b *Buffer, ids ...string) {
+	for _, id := range ids {
+		b.Push(&types.GRUniChatMessage{TotalID: id})
+	}
+}
+
+func TestBufferPersistsAndDropsOldest(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "sub", "buffer.jsonl")
+	b, err := NewBuffer(3, time.Minute, file, testLogger())
+	if err != nil {
+		t.Fatalf("NewBuffer() error = %v", err)
+	}
+	pushIDs(b, "a", "b", "c", "d")
+
+	restored, err := NewBuffer(3, time.Minute, file, testLogger())
+	if err != nil {
+		t.Fatalf("NewBuffer() error = %v", err)
+	}
+	var ids []string
+	restored.Replay(func(message *types.GRUniChatMessage) error {
+		ids = append(ids, message.TotalID)
+		return nil
+	})
+	if len(ids) != 3 || ids[0] != "b" || ids[2] != "d" {
+		t.Fatalf("replayed %v, want [b c d]", ids)
+	}
+	if restored.Len() != 0 {
+		t.Fatalf("Len() = %d after replay, want 0", restored.Len())
+	}
+}
+
+func TestBufferReplayStopsOnError(t *testing.T) {
+	b, _ := NewBuffer(10, time.Minute, "", testLogger())
+	pushIDs(b, "a", "b", "c")
+
+	sent, err := b.Replay(func(message *types.GRUniChatMessage) error {
+		if message.TotalID == "b" {
+			return errors.New("send failed")
+		}
+		return nil
+	})
+	if sent != 1 || err == nil {
+		t.Fatalf("Replay() = %d, %v, want 1 and an error", sent, err)
+	}
+	if b.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", b.Len())
+	}
+}
+
+func TestBufferReplayDoesNotBlockPush(t *testing.T) {
+	b, _ := NewBuffer(10, time.Minute, "", testLogger())
+	pushIDs(b, "a")
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		b.Replay(func(message *types.GRUniChatMessage) error {
+			close(entered)
+			<-release
+			return nil
+		})
+		close(done)
+	}()
+
+	<-entered
+	pushed := make(chan struct{})
+	go func() {
+		pushIDs(b, "b")
+		close(pushed)
+	}()
+	select {
+	case <-pushed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Push() blocked behind a slow replay")
+	}
+	close(release)
+	<-done
+
+	if b.Len() != 1 {
+		t.Fatalf("Len() = %d, want the message pushed during replay to remain", b.Len())
+	}
+}
+
+// 记录发送顺序的GRUniChat连接
+type fakeConnection struct {
+	mu        sync.Mutex
+	connected bool
+	sent      []*types.GRUniChatMessage
+}
+
+func (f *fakeConnection) Connect(ctx context.Context) error      { return nil }
+func (f *fakeConnection) SetMessageHandler(handler func([]byte)) {}
+func (f *fakeConnection) Close() error                           { return nil }
+
+func (f *fakeConnection) IsConnected() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.connected
+}
+
+func (f *fakeConnection) SendMessage(message interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.connected {
+		return errors.New("not connected")
+	}
+	f.sent = append(f.sent, message.(*types.GRUniChatMessage))
+	return nil
+}
+
+func TestBufferedManagerReplaysInOrder(t *testing.T) {
+	conn := &fakeConnection{}
+	b, _ := NewBuffer(10, time.Minute, "", testLogger())
+	m := NewBufferedManager(conn, b, testLogger())
+
+	original := &types.GRUniChatMessage{TotalID: "a"}
+	m.SendMessage(original)
+	m.SendMessage(&types.GRUniChatMessage{TotalID: "b"})
+	if m.PendingCount() != 2 {
+		t.Fatalf("PendingCount() = %d, want 2", m.PendingCount())
+	}
+
+	conn.mu.Lock()
+	conn.connected = true
+	conn.mu.Unlock()
+	m.Replay()
+	m.SendMessage(&types.GRUniChatMessage{TotalID: "c"})
+
+	if len(conn.sent) != 3 || conn.sent[0].TotalID != "a" || conn.sent[2].TotalID != "c" {
+		t.Fatalf("sent %d messages out of order", len(conn.sent))
+	}
+	if conn.sent[0].Extra["replayed"] != true || conn.sent[2].Extra["replayed"] != nil {
+		t.Error("only replayed messages should be marked as replayed")
+	}
+	if original.Extra != nil {
+		t.Error("Replay() modified the buffered message")
+	}
+}
@@ -0,0 +1,96 @@
+package offline
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"grunichat-onebot-adapter/internal/metrics"
+	"grunichat-onebot-adapter/internal/types"
+	"grunichat-onebot-adapter/internal/websocket"
+)
+
+// 带离线缓存的GRUniChat连接，未连接或发送失败时缓存消息，重连后按顺序重放
+type BufferedManager struct {
+	websocket.IWebSocketManager
+	buffer   *Buffer
+	logger   *logrus.Logger
+	sendMu   sync.Mutex // 缓存非空时新消息进入缓存，保证缓存消息先于新消息发送
+	replayMu sync.Mutex // 同一时间只有一个重放过程
+}
+
+// 创建带离线缓存的GRUniChat连接
+func NewBufferedManager(manager websocket.IWebSocketManager, buffer *Buffer, logger *logrus.Logger) *BufferedManager {
+	return &BufferedManager{
+		IWebSocketManager: manager,
+		buffer:            buffer,
+		logger:            logger,
+	}
+}
+
+// 发送消息，GRUniChat消息在无法发送时进入缓存
+func (m *BufferedManager) SendMessage(message interface{}) error {
+	gruni, ok := message.(*types.GRUniChatMessage)
+	if !ok {
+		return m.IWebSocketManager.SendMessage(message)
+	}
+
+	m.sendMu.Lock()
+	defer m.sendMu.Unlock()
+
+	// 缓存中还有消息时也进入缓存，避免新消息先于旧消息到达
+	if m.IsConnected() && m.buffer.Len() == 0 {
+		err := m.IWebSocketManager.SendMessage(gruni)
+		if err == nil {
+			return nil
+		}
+		m.logger.Warnf("Failed to send message to GRUniChat, buffering: %v", err)
+		metrics.SendFailuresTotal.Inc("grunichat")
+	}
+
+	m.buffer.Push(gruni)
+	m.logger.Debugf("GRUniChat not available, buffered message %s (%d pending)", gruni.TotalID, m.buffer.Len())
+	return nil
+}
+
+// 重放缓存的消息，重放的消息保留原始 currentTime 并在 extra 中标记 replayed
+// 重放期间不阻塞 SendMessage，新消息进入缓存并在本次重放中一并发送
+func (m *BufferedManager) Replay() {
+	m.replayMu.Lock()
+	defer m.replayMu.Unlock()
+
+	total := 0
+	for m.buffer.Len() > 0 {
+		sent, err := m.buffer.Replay(func(message *types.GRUniChatMessage) error {
+			return m.IWebSocketManager.SendMessage(markReplayed(message))
+		})
+		total += sent
+		if err != nil {
+			m.logger.Warnf("Replayed %d buffered messages to GRUniChat, %d remaining: %v", total, m.buffer.Len(), err)
+			return
+		}
+		if sent == 0 {
+			break
+		}
+	}
+
+	if total > 0 {
+		m.logger.Infof("Replayed %d buffered messages to GRUniChat", total)
+	}
+}
+
+// 复制消息并在 extra 中标记 replayed，缓存中的消息可能正在被写入持久化文件，不能直接修改
+func markReplayed(message *types.GRUniChatMessage) *types.GRUniChatMessage {
+	replayed := *message
+	replayed.Extra = make(map[string]interface{}, len(message.Extra)+1)
+	for key, value := range message.Extra {
+		replayed.Extra[key] = value
+	}
+	replayed.Extra["replayed"] = true
+	return &replayed
+}
+
+// 缓存中待重放的消息数量
+func (m *BufferedManager) PendingCount() int {
+	return m.buffer.Len()
+}
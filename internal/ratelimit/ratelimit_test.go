@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucketBurst(t *testing.T) {
+	bucket := NewTokenBucket(1, 3)
+
+	for i := 0; i < 3; i++ {
+		if !bucket.Allow() {
+			t.Fatalf("Allow() #%d = false within burst", i+1)
+		}
+	}
+	if bucket.Available() {
+		t.Error("Available() = true after the burst was used")
+	}
+	if bucket.Allow() {
+		t.Error("Allow() = true after the burst was used")
+	}
+}
+
+func TestTokenBucketRefill(t *testing.T) {
+	bucket := NewTokenBucket(60, 1)
+	if !bucket.Allow() {
+		t.Fatal("Allow() = false on a full bucket")
+	}
+
+	// 每秒补充一个令牌
+	bucket.last = bucket.last.Add(-time.Second)
+	if !bucket.Available() {
+		t.Fatal("Available() = false after one second")
+	}
+	if !bucket.Allow() {
+		t.Fatal("Allow() = false after one second")
+	}
+	if bucket.Allow() {
+		t.Error("Allow() = true before the next refill")
+	}
+
+	// 长时间空闲后令牌数不超过桶容量
+	bucket.last = bucket.last.Add(-time.Hour)
+	bucket.Allow()
+	if bucket.Allow() {
+		t.Error("bucket refilled beyond its burst")
+	}
+}
+
+func TestTokenBucketUnlimited(t *testing.T) {
+	bucket := NewTokenBucket(0, 0)
+	for i := 0; i < 100; i++ {
+		if !bucket.Allow() {
+			t.Fatal("Allow() = false with perMinute <= 0")
+		}
+	}
+}
+
+func TestTokenBucketFull(t *testing.T) {
+	bucket := NewTokenBucket(60, 2)
+	if !bucket.Full() {
+		t.Fatal("Full() = false on a new bucket")
+	}
+
+	bucket.Allow()
+	if bucket.Full() {
+		t.Error("Full() = true after a token was used")
+	}
+	bucket.last = bucket.last.Add(-time.Second)
+	if !bucket.Full() {
+		t.Error("Full() = false after the bucket refilled")
+	}
+}
@@ -0,0 +1,51 @@
+package recorder
+
+import (
+	"encoding/json"
+
+	"grunichat-onebot-adapter/internal/websocket"
+)
+
+// 帧的接收方，例如记录文件或镜像连接
+type IFrameSink interface {
+	Record(connection, direction string, data []byte)
+}
+
+// 记录收发帧的连接包装，收到的帧在交给消息处理器前记录，发送的帧在发送成功后记录
+type RecordingManager struct {
+	websocket.IWebSocketManager
+	connection string
+	recorder   IFrameSink
+}
+
+// 创建记录收发帧的连接包装
+func NewRecordingManager(manager websocket.IWebSocketManager, connection string, recorder IFrameSink) *RecordingManager {
+	return &RecordingManager{
+		IWebSocketManager: manager,
+		connection:        connection,
+		recorder:          recorder,
+	}
+}
+
+// 设置消息处理器，收到的原始帧会先被记录
+func (m *RecordingManager) SetMessageHandler(handler func(message []byte)) {
+	m.IWebSocketManager.SetMessageHandler(func(message []byte) {
+		m.recorder.Record(m.connection, DirectionReceived, message)
+		if handler != nil {
+			handler(message)
+		}
+	})
+}
+
+// 发送消息并记录发送的帧
+func (m *RecordingManager) SendMessage(message interface{}) error {
+	if err := m.IWebSocketManager.SendMessage(message); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(message)
+	if err == nil {
+		m.recorder.Record(m.connection, DirectionSent, data)
+	}
+	return nil
+}
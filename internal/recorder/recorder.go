@@ -0,0 +1,128 @@
+package recorder
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 连接名称
+const (
+	ConnectionOneBot    = "onebot"
+	ConnectionGRUniChat = "grunichat"
+)
+
+// 帧方向
+const (
+	DirectionReceived = "recv" // 从对端收到
+	DirectionSent     = "send" // 发送给对端
+)
+
+// 记录的一帧
+type Frame struct {
+	Time       time.Time       `json:"time"`
+	Connection string          `json:"connection"` // onebot, grunichat
+	Direction  string          `json:"direction"`  // recv, send
+	Data       json.RawMessage `json:"data"`
+}
+
+// 流量记录器，将收发的原始帧追加写入JSONL文件
+type Recorder struct {
+	logger *logrus.Logger
+	mu     sync.Mutex
+	file   *os.File
+}
+
+// 创建流量记录器
+func NewRecorder(path string, logger *logrus.Logger) (*Recorder, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording file: %w", err)
+	}
+
+	return &Recorder{
+		logger: logger,
+		file:   file,
+	}, nil
+}
+
+// 记录一帧
+func (r *Recorder) Record(connection, direction string, data []byte) {
+	frame := Frame{
+		Time:       time.Now(),
+		Connection: connection,
+		Direction:  direction,
+		Data:       json.RawMessage(data),
+	}
+	if !json.Valid(data) {
+		// 非JSON帧按字符串保存
+		quoted, _ := json.Marshal(string(data))
+		frame.Data = quoted
+	}
+
+	line, err := json.Marshal(frame)
+	if err != nil {
+		r.logger.Errorf("Failed to encode recorded frame: %v", err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return
+	}
+	if _, err := r.file.Write(append(line, '\n')); err != nil {
+		r.logger.Errorf("Failed to write recorded frame: %v", err)
+	}
+}
+
+// 关闭记录文件
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// 读取记录文件中的全部帧
+func Load(path string) ([]Frame, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer file.Close()
+
+	var frames []Frame
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var frame Frame
+		if err := json.Unmarshal(scanner.Bytes(), &frame); err != nil {
+			return nil, fmt.Errorf("invalid frame at line %d: %w", line, err)
+		}
+		frames = append(frames, frame)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	return frames, nil
+}
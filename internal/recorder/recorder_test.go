@@ -0,0 +1,139 @@
+package recorder
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newTestRecorder(t *testing.T) (*Recorder, string) {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	path := filepath.Join(t.TempDir(), "recordings", "traffic.jsonl")
+	rec, err := NewRecorder(path, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rec.Close() })
+	return rec, path
+}
+
+func TestRecordLoadRoundTrip(t *testing.T) {
+	rec, path := newTestRecorder(t)
+
+	rec.Record(ConnectionOneBot, DirectionReceived, []byte(`{"post_type":"message","message":"hi"}`))
+	rec.Record(ConnectionGRUniChat, DirectionSent, []byte(`{"type":"chat"}`))
+	rec.Record(ConnectionGRUniChat, DirectionReceived, []byte("not json"))
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// 关闭后的记录被忽略
+	rec.Record(ConnectionOneBot, DirectionSent, []byte(`{}`))
+
+	frames, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		connection string
+		direction  string
+		data       string
+	}{
+		{ConnectionOneBot, DirectionReceived, `{"post_type":"message","message":"hi"}`},
+		{ConnectionGRUniChat, DirectionSent, `{"type":"chat"}`},
+		{ConnectionGRUniChat, DirectionReceived, `"not json"`}, // 非JSON帧按字符串保存
+	}
+	if len(frames) != len(want) {
+		t.Fatalf("Load() returned %d frames, want %d", len(frames), len(want))
+	}
+	for i, w := range want {
+		frame := frames[i]
+		if frame.Connection != w.connection || frame.Direction != w.direction || string(frame.Data) != w.data {
+			t.Errorf("frame %d = %s %s %s, want %s %s %s", i, frame.Connection, frame.Direction, frame.Data, w.connection, w.direction, w.data)
+		}
+		if frame.Time.IsZero() {
+			t.Errorf("frame %d has no time", i)
+		}
+	}
+}
+
+func TestLoadReportsInvalidLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.jsonl")
+	content := `{"connection":"onebot","direction":"recv","data":{}}` + "\n\n" + "{broken\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("Load() error = %v, want the invalid line number", err)
+	}
+}
+
+// 记录发送内容的测试连接
+type fakeManager struct {
+	handler func(message []byte)
+	sent    []interface{}
+	sendErr error
+}
+
+func (m *fakeManager) Connect(ctx context.Context) error { return nil }
+func (m *fakeManager) Close() error                      { return nil }
+func (m *fakeManager) IsConnected() bool                 { return true }
+
+func (m *fakeManager) SetMessageHandler(handler func(message []byte)) {
+	m.handler = handler
+}
+
+func (m *fakeManager) SendMessage(message interface{}) error {
+	if m.sendErr != nil {
+		return m.sendErr
+	}
+	m.sent = append(m.sent, message)
+	return nil
+}
+
+// 在内存中收集帧的接收方
+type frameSink struct {
+	frames []string
+}
+
+func (s *frameSink) Record(connection, direction string, data []byte) {
+	s.frames = append(s.frames, connection+" "+direction+" "+string(data))
+}
+
+func TestRecordingManager(t *testing.T) {
+	inner := &fakeManager{}
+	sink := &frameSink{}
+	manager := NewRecordingManager(inner, ConnectionOneBot, sink)
+
+	var handled []string
+	manager.SetMessageHandler(func(message []byte) { handled = append(handled, string(message)) })
+	inner.handler([]byte(`{"post_type":"meta_event"}`))
+
+	if err := manager.SendMessage(map[string]string{"action": "send_group_msg"}); err != nil {
+		t.Fatal(err)
+	}
+	// 发送失败的帧不记录
+	inner.sendErr = errors.New("closed")
+	if err := manager.SendMessage(map[string]string{"action": "get_status"}); err == nil {
+		t.Fatal("SendMessage() error = nil, want the connection error")
+	}
+
+	want := []string{
+		`onebot recv {"post_type":"meta_event"}`,
+		`onebot send {"action":"send_group_msg"}`,
+	}
+	if strings.Join(sink.frames, "\n") != strings.Join(want, "\n") {
+		t.Errorf("recorded frames = %q, want %q", sink.frames, want)
+	}
+	if len(handled) != 1 || len(inner.sent) != 1 {
+		t.Errorf("handled %d frame(s) and sent %d, want 1 and 1", len(handled), len(inner.sent))
+	}
+}
@@ -0,0 +1,372 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"grunichat-onebot-adapter/internal/adapter"
+	"grunichat-onebot-adapter/internal/config"
+	"grunichat-onebot-adapter/internal/recorder"
+	"grunichat-onebot-adapter/internal/sim/grunichatsim"
+	"grunichat-onebot-adapter/internal/sim/onebotsim"
+	"grunichat-onebot-adapter/internal/types"
+	"grunichat-onebot-adapter/internal/websocket"
+)
+
+// 每次运行都不同的字段，比较前去除
+var volatileFields = []string{"echo", "totalId", "currentTime"}
+
+// 连接建立的超时时间
+const connectTimeout = 10 * time.Second
+
+// 回放选项
+type Options struct {
+	Speed  float64       // 回放速度倍数，0表示不等待，尽快回放
+	MaxGap time.Duration // 两帧之间的最长等待时间，0表示不限制
+	Settle time.Duration // 等待适配器产生输出的最长时间（每次输入前和回放完毕后）
+}
+
+// 一处差异
+type Diff struct {
+	Connection string
+	Index      int    // 该连接发送的第几帧（从0开始）
+	Expected   string // 记录中的帧，为空表示回放时多出的帧
+	Actual     string // 回放时的帧，为空表示回放时缺少的帧
+}
+
+// 回放结果
+type Result struct {
+	Expected map[string][]string // 连接 -> 记录中发送的帧（已规范化）
+	Actual   map[string][]string // 连接 -> 回放时发送的帧（已规范化）
+	Diffs    []Diff
+}
+
+// 回放结果与记录是否一致
+func (r *Result) OK() bool {
+	return len(r.Diffs) == 0
+}
+
+// 输出差异报告
+func (r *Result) Write(w io.Writer) {
+	for _, connection := range []string{recorder.ConnectionOneBot, recorder.ConnectionGRUniChat} {
+		count := 0
+		for _, diff := range r.Diffs {
+			if diff.Connection == connection {
+				count++
+			}
+		}
+		fmt.Fprintf(w, "%s: %d recorded, %d replayed, %d differences\n",
+			connection, len(r.Expected[connection]), len(r.Actual[connection]), count)
+
+		for _, diff := range r.Diffs {
+			if diff.Connection != connection {
+				continue
+			}
+			fmt.Fprintf(w, "  #%d\n", diff.Index)
+			if diff.Expected != "" {
+				fmt.Fprintf(w, "    - %s\n", diff.Expected)
+			}
+			if diff.Actual != "" {
+				fmt.Fprintf(w, "    + %s\n", diff.Actual)
+			}
+		}
+	}
+}
+
+// 将记录的帧输入到连接模拟服务端的适配器中，比较适配器发送的帧与记录是否一致
+func Run(ctx context.Context, cfg *config.Config, logger *logrus.Logger, frames []recorder.Frame, opts Options) (*Result, error) {
+	onebot := onebotsim.NewServer()
+	defer onebot.Close()
+	grunichat := grunichatsim.NewServer()
+	defer grunichat.Close()
+
+	// 按记录中的API响应回复适配器的请求
+	for action, responder := range recordedResponders(frames) {
+		onebot.OnAction(action, responder)
+	}
+
+	// 回放时产生的文件写入临时目录，不影响正式数据
+	tempDir, err := os.MkdirTemp("", "grunichat-replay-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// 模拟服务端在本地直接连接且不要求认证，清除代理、TLS、附加请求头和认证设置
+	replayCfg := *cfg
+	replayCfg.OneBot.WebSocketURL = onebot.URL()
+	replayCfg.OneBot.AccessToken = ""
+	replayCfg.OneBot.Proxy = ""
+	replayCfg.OneBot.TLS = config.TLSConfig{}
+	replayCfg.OneBot.Headers = nil
+	replayCfg.GRUniChat.URL = grunichat.URL()
+	replayCfg.GRUniChat.Proxy = ""
+	replayCfg.GRUniChat.TLS = config.TLSConfig{}
+	replayCfg.GRUniChat.Headers = nil
+	replayCfg.GRUniChat.Auth.Mode = websocket.AuthNone
+	replayCfg.GRUniChat.Auth.Token = ""
+	replayCfg.HTTP.Enabled = false
+	replayCfg.Recorder.Enabled = false
+	replayCfg.DryRun.Enabled = false
+	replayCfg.Mirror.URL = ""
+	replayCfg.Offline.File = ""
+	replayCfg.History.Dir = filepath.Join(tempDir, "history")
+	replayCfg.Media.CacheDir = filepath.Join(tempDir, "media")
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	instance := adapter.NewModularAdapter(&replayCfg, logger)
+	done := make(chan error, 1)
+	go func() { done <- instance.Start(runCtx) }()
+
+	if err := waitForConnections(onebot, grunichat, done); err != nil {
+		return nil, err
+	}
+
+	expected := recordedOutputs(frames)
+	if err := feed(runCtx, onebot, grunichat, frames, opts); err != nil {
+		return nil, err
+	}
+
+	waitForOutputs(runCtx, onebot, grunichat, expected, opts.Settle)
+
+	// 关闭适配器后再收集输出，关闭时发送的帧（例如下线事件）也参与比较
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(connectTimeout + time.Duration(replayCfg.Shutdown.Timeout)*time.Second):
+		logger.Warn("Adapter did not shut down in time after replay")
+	}
+	actual := collectOutputs(onebot, grunichat)
+
+	return &Result{
+		Expected: expected,
+		Actual:   actual,
+		Diffs:    diff(expected, actual),
+	}, nil
+}
+
+// 等待适配器连接到两个模拟服务端
+func waitForConnections(onebot *onebotsim.Server, grunichat *grunichatsim.Server, done <-chan error) error {
+	connected := make(chan error, 1)
+	go func() {
+		if err := onebot.WaitForConnection(connectTimeout); err != nil {
+			connected <- err
+			return
+		}
+		connected <- grunichat.WaitForClient(connectTimeout)
+	}()
+
+	select {
+	case err := <-connected:
+		return err
+	case err := <-done:
+		if err == nil {
+			err = fmt.Errorf("adapter stopped before connecting")
+		}
+		return fmt.Errorf("failed to start adapter: %w", err)
+	}
+}
+
+// 从记录中提取OneBot API响应，按API名称和顺序回复
+func recordedResponders(frames []recorder.Frame) map[string]onebotsim.Responder {
+	actionsByEcho := make(map[string]string)
+	queues := make(map[string][]*types.OneBotResponse)
+
+	for _, frame := range frames {
+		if frame.Connection != recorder.ConnectionOneBot {
+			continue
+		}
+
+		switch frame.Direction {
+		case recorder.DirectionSent:
+			var request struct {
+				Action string `json:"action"`
+				Echo   string `json:"echo"`
+			}
+			if json.Unmarshal(frame.Data, &request) == nil && request.Echo != "" {
+				actionsByEcho[request.Echo] = request.Action
+			}
+		case recorder.DirectionReceived:
+			if !isAPIResponse(frame.Data) {
+				continue
+			}
+			var response types.OneBotResponse
+			if json.Unmarshal(frame.Data, &response) != nil {
+				continue
+			}
+			if action, exists := actionsByEcho[response.Echo]; exists {
+				queues[action] = append(queues[action], &response)
+			}
+		}
+	}
+
+	responders := make(map[string]onebotsim.Responder)
+	for action, queue := range queues {
+		var mu sync.Mutex
+		remaining := queue
+		responders[action] = func(*onebotsim.Action) *types.OneBotResponse {
+			mu.Lock()
+			defer mu.Unlock()
+			if len(remaining) == 0 {
+				return &types.OneBotResponse{Status: "ok", RetCode: 0}
+			}
+			response := *remaining[0]
+			remaining = remaining[1:]
+			return &response
+		}
+	}
+	return responders
+}
+
+// 是否为OneBot API响应（没有 post_type 且带有 echo）
+func isAPIResponse(data []byte) bool {
+	var frame struct {
+		PostType string `json:"post_type"`
+		Echo     string `json:"echo"`
+	}
+	return json.Unmarshal(data, &frame) == nil && frame.PostType == "" && frame.Echo != ""
+}
+
+// 按记录的顺序和时间间隔输入收到的帧；输入每一帧前先等待记录中在它之前发送的帧都已产生，保证跨连接的先后顺序
+func feed(ctx context.Context, onebot *onebotsim.Server, grunichat *grunichatsim.Server, frames []recorder.Frame, opts Options) error {
+	var last time.Time
+	sentBefore := make(map[string]int)
+	for _, frame := range frames {
+		if frame.Direction == recorder.DirectionSent {
+			sentBefore[frame.Connection]++
+			continue
+		}
+		if frame.Connection == recorder.ConnectionOneBot && isAPIResponse(frame.Data) {
+			continue // API响应由模拟服务端按请求回复
+		}
+
+		if opts.Speed > 0 && !last.IsZero() {
+			gap := time.Duration(float64(frame.Time.Sub(last)) / opts.Speed)
+			if opts.MaxGap > 0 && gap > opts.MaxGap {
+				gap = opts.MaxGap
+			}
+			if gap > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(gap):
+				}
+			}
+		}
+		last = frame.Time
+
+		waitForCounts(ctx, onebot, grunichat, sentBefore, opts.Settle)
+
+		var err error
+		switch frame.Connection {
+		case recorder.ConnectionOneBot:
+			err = onebot.PushEvent(frame.Data)
+		case recorder.ConnectionGRUniChat:
+			err = grunichat.SendRaw(frame.Data)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to replay %s frame from %s: %w", frame.Connection, frame.Time.Format(time.RFC3339Nano), err)
+		}
+	}
+	return nil
+}
+
+// 等待两个连接上收到的帧数达到指定数量，超时后继续
+func waitForCounts(ctx context.Context, onebot *onebotsim.Server, grunichat *grunichatsim.Server, counts map[string]int, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if len(onebot.Actions()) >= counts[recorder.ConnectionOneBot] &&
+			len(grunichat.Messages()) >= counts[recorder.ConnectionGRUniChat] {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// 记录中适配器发送的帧
+func recordedOutputs(frames []recorder.Frame) map[string][]string {
+	outputs := make(map[string][]string)
+	for _, frame := range frames {
+		if frame.Direction == recorder.DirectionSent {
+			outputs[frame.Connection] = append(outputs[frame.Connection], normalize(frame.Data))
+		}
+	}
+	return outputs
+}
+
+// 等待回放的输出数量达到记录中的数量，最长等待 settle
+func waitForOutputs(ctx context.Context, onebot *onebotsim.Server, grunichat *grunichatsim.Server, expected map[string][]string, settle time.Duration) {
+	// 记录中最后的帧可能是关闭时发送的，这里只等待到超时或数量达到
+	waitForCounts(ctx, onebot, grunichat, map[string]int{
+		recorder.ConnectionOneBot:    len(expected[recorder.ConnectionOneBot]),
+		recorder.ConnectionGRUniChat: len(expected[recorder.ConnectionGRUniChat]),
+	}, settle)
+
+	// 再等待一小段时间，发现多出的帧
+	time.Sleep(200 * time.Millisecond)
+}
+
+// 收集回放时适配器发送的帧
+func collectOutputs(onebot *onebotsim.Server, grunichat *grunichatsim.Server) map[string][]string {
+	actual := make(map[string][]string)
+	for _, action := range onebot.Actions() {
+		actual[recorder.ConnectionOneBot] = append(actual[recorder.ConnectionOneBot], normalize(action.Raw))
+	}
+	for _, msg := range grunichat.Messages() {
+		actual[recorder.ConnectionGRUniChat] = append(actual[recorder.ConnectionGRUniChat], normalize(msg.Raw))
+	}
+	return actual
+}
+
+// 去除每次运行都不同的字段，输出键排序后的JSON
+func normalize(data []byte) string {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return string(data)
+	}
+	if object, ok := value.(map[string]interface{}); ok {
+		for _, field := range volatileFields {
+			delete(object, field)
+		}
+	}
+	normalized, err := json.Marshal(value)
+	if err != nil {
+		return string(data)
+	}
+	return string(normalized)
+}
+
+// 逐帧比较每个连接的输出
+func diff(expected, actual map[string][]string) []Diff {
+	var diffs []Diff
+	for _, connection := range []string{recorder.ConnectionOneBot, recorder.ConnectionGRUniChat} {
+		want, got := expected[connection], actual[connection]
+		for i := 0; i < len(want) || i < len(got); i++ {
+			var w, g string
+			if i < len(want) {
+				w = want[i]
+			}
+			if i < len(got) {
+				g = got[i]
+			}
+			if w != g {
+				diffs = append(diffs, Diff{Connection: connection, Index: i, Expected: w, Actual: g})
+			}
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,170 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"grunichat-onebot-adapter/internal/config"
+	"grunichat-onebot-adapter/internal/recorder"
+	"grunichat-onebot-adapter/internal/websocket"
+)
+
+var testOptions = Options{Settle: 2 * time.Second}
+
+func replayConfig() *config.Config {
+	cfg := config.Default()
+	cfg.Filter.ServiceGroups = []int64{100}
+	cfg.GRUniChat.ReconnectInterval = 1
+	cfg.Shutdown.Timeout = 1
+	return cfg
+}
+
+func newTestLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+// 记录中收到的帧：一条QQ群消息和一条游戏聊天消息
+func inputFrames(t *testing.T) []recorder.Frame {
+	t.Helper()
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+	frame := func(offset time.Duration, connection string, data interface{}) recorder.Frame {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return recorder.Frame{Time: start.Add(offset), Connection: connection, Direction: recorder.DirectionReceived, Data: raw}
+	}
+	return []recorder.Frame{
+		frame(0, recorder.ConnectionOneBot, map[string]interface{}{
+			"post_type": "message", "message_type": "group", "sub_type": "normal",
+			"time": start.Unix(), "self_id": 1, "message_id": 1, "group_id": 100, "user_id": 12345,
+			"message": "hello", "raw_message": "hello",
+			"sender": map[string]interface{}{"user_id": 12345, "nickname": "Steve"},
+		}),
+		frame(time.Second, recorder.ConnectionGRUniChat, map[string]interface{}{
+			"from": "survival", "type": "chat", "currentTime": "2024-05-01 12:00:01",
+			"body": map[string]interface{}{"sender": "Alex", "chatMessage": "hi", "executeAt": "group_100"},
+		}),
+	}
+}
+
+// 将一次回放的输出作为记录中发送的帧，追加到输入帧之后
+func withOutputs(inputs []recorder.Frame, outputs map[string][]string) []recorder.Frame {
+	frames := append([]recorder.Frame{}, inputs...)
+	last := inputs[len(inputs)-1].Time
+	for _, connection := range []string{recorder.ConnectionOneBot, recorder.ConnectionGRUniChat} {
+		for _, data := range outputs[connection] {
+			frames = append(frames, recorder.Frame{Time: last, Connection: connection, Direction: recorder.DirectionSent, Data: json.RawMessage(data)})
+		}
+	}
+	return frames
+}
+
+func TestRunMatchesRecording(t *testing.T) {
+	inputs := inputFrames(t)
+	first, err := Run(context.Background(), replayConfig(), newTestLogger(), inputs, testOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Actual[recorder.ConnectionGRUniChat]) == 0 || len(first.Actual[recorder.ConnectionOneBot]) == 0 {
+		t.Fatalf("replay produced no output: %+v", first.Actual)
+	}
+
+	result, err := Run(context.Background(), replayConfig(), newTestLogger(), withOutputs(inputs, first.Actual), testOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.OK() {
+		var report bytes.Buffer
+		result.Write(&report)
+		t.Errorf("replay differs from its own recording:\n%s", report.String())
+	}
+}
+
+func TestRunReportsDifferences(t *testing.T) {
+	inputs := inputFrames(t)
+	first, err := Run(context.Background(), replayConfig(), newTestLogger(), inputs, testOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 记录中的聊天消息与回放不同，且记录中多出一帧
+	recorded := map[string][]string{
+		recorder.ConnectionOneBot:    first.Actual[recorder.ConnectionOneBot],
+		recorder.ConnectionGRUniChat: append([]string{}, first.Actual[recorder.ConnectionGRUniChat]...),
+	}
+	chat := len(recorded[recorder.ConnectionGRUniChat]) - 1
+	recorded[recorder.ConnectionGRUniChat][chat] = strings.Replace(recorded[recorder.ConnectionGRUniChat][chat], "hello", "goodbye", 1)
+	recorded[recorder.ConnectionGRUniChat] = append(recorded[recorder.ConnectionGRUniChat], `{"type":"missing"}`)
+
+	result, err := Run(context.Background(), replayConfig(), newTestLogger(), withOutputs(inputs, recorded), testOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Diffs) != 2 {
+		t.Fatalf("diffs = %+v, want the changed and the missing frame", result.Diffs)
+	}
+	changed, missing := result.Diffs[0], result.Diffs[1]
+	if changed.Connection != recorder.ConnectionGRUniChat || !strings.Contains(changed.Expected, "goodbye") || !strings.Contains(changed.Actual, "hello") {
+		t.Errorf("changed frame diff = %+v", changed)
+	}
+	if missing.Expected != `{"type":"missing"}` || missing.Actual != "" {
+		t.Errorf("missing frame diff = %+v", missing)
+	}
+
+	var report bytes.Buffer
+	result.Write(&report)
+	if !strings.Contains(report.String(), "grunichat: ") || !strings.Contains(report.String(), "2 differences") {
+		t.Errorf("report = %q", report.String())
+	}
+}
+
+func TestRunIgnoresConnectionSettings(t *testing.T) {
+	// 正式环境的代理、TLS和请求头在回放时不可用
+	cfg := replayConfig()
+	cfg.OneBot.Proxy = "http://127.0.0.1:1"
+	cfg.OneBot.TLS.CAFile = "missing-ca.pem"
+	cfg.OneBot.Headers = map[string]string{"Host": "onebot.internal"}
+	cfg.GRUniChat.Proxy = "socks5://127.0.0.1:1"
+	cfg.GRUniChat.TLS.CertFile = "missing-cert.pem"
+	cfg.GRUniChat.TLS.KeyFile = "missing-key.pem"
+	cfg.GRUniChat.Headers = map[string]string{"Authorization": "Bearer secret"}
+
+	result, err := Run(context.Background(), cfg, newTestLogger(), inputFrames(t), testOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Actual[recorder.ConnectionGRUniChat]) == 0 || len(result.Actual[recorder.ConnectionOneBot]) == 0 {
+		t.Errorf("replay produced no output: %+v", result.Actual)
+	}
+}
+
+func TestRunIgnoresAuthSettings(t *testing.T) {
+	// 模拟服务端不要求认证，正式环境的认证设置不能阻止回放连接
+	for _, mode := range []string{websocket.AuthToken, websocket.AuthHMAC, websocket.AuthBearer} {
+		t.Run(mode, func(t *testing.T) {
+			cfg := replayConfig()
+			cfg.GRUniChat.Auth.Mode = mode
+			cfg.GRUniChat.Auth.Token = "secret"
+			cfg.GRUniChat.Auth.AckTimeout = 1
+
+			result, err := Run(context.Background(), cfg, newTestLogger(), inputFrames(t), testOptions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Actual[recorder.ConnectionGRUniChat]) == 0 || len(result.Actual[recorder.ConnectionOneBot]) == 0 {
+				t.Errorf("replay produced no output: %+v", result.Actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package sender
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"grunichat-onebot-adapter/internal/types"
+	"grunichat-onebot-adapter/internal/websocket"
+)
+
+// OneBot API调用接口
+type IActionCaller interface {
+	CallAction(ctx context.Context, action string, params map[string]interface{}) (*types.OneBotResponse, error)
+}
+
+// OneBot API客户端，通过echo字段关联请求与响应
+type OneBotAPIClient struct {
+	wsManager websocket.IWebSocketManager
+	logger    *logrus.Logger
+	mu        sync.Mutex
+	pending   map[string]chan *types.OneBotResponse // key: echo
+}
+
+// 创建OneBot API客户端
+func NewOneBotAPIClient(wsManager websocket.IWebSocketManager, logger *logrus.Logger) *OneBotAPIClient {
+	return &OneBotAPIClient{
+		wsManager: wsManager,
+		logger:    logger,
+		pending:   make(map[string]chan *types.OneBotResponse),
+	}
+}
+
+// 调用OneBot API并等待响应
+func (c *OneBotAPIClient) CallAction(ctx context.Context, action string, params map[string]interface{}) (*types.OneBotResponse, error) {
+	if !c.wsManager.IsConnected() {
+		return nil, fmt.Errorf("OneBot WebSocket not connected")
+	}
+
+	echo := "api_" + uuid.New().String()
+	responseChan := make(chan *types.OneBotResponse, 1)
+
+	c.mu.Lock()
+	c.pending[echo] = responseChan
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, echo)
+		c.mu.Unlock()
+	}()
+
+	request := map[string]interface{}{
+		"action": action,
+		"params": params,
+		"echo":   echo,
+	}
+	if err := c.wsManager.SendMessage(request); err != nil {
+		return nil, fmt.Errorf("failed to send %s request: %w", action, err)
+	}
+
+	select {
+	case response := <-responseChan:
+		if response.Status == "failed" {
+			return response, fmt.Errorf("%s failed with retcode %d", action, response.RetCode)
+		}
+		return response, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%s request cancelled: %w", action, ctx.Err())
+	}
+}
+
+// 处理API响应，返回是否匹配到等待中的请求
+// 在OneBot读取协程中调用，不能阻塞：每个echo只投递一次，重复或迟到的响应直接忽略
+func (c *OneBotAPIClient) HandleResponse(response *types.OneBotResponse) bool {
+	c.mu.Lock()
+	responseChan, exists := c.pending[response.Echo]
+	delete(c.pending, response.Echo)
+	c.mu.Unlock()
+
+	if !exists {
+		return false
+	}
+
+	select {
+	case responseChan <- response:
+	default:
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+package sender
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"grunichat-onebot-adapter/internal/types"
+)
+
+// 记录请求并可模拟响应的WebSocket管理器
+type fakeWebSocketManager struct {
+	mu       sync.Mutex
+	requests []map[string]interface{}
+	sent     chan string // 每次发送请求时写入echo
+}
+
+func (f *fakeWebSocketManager) Connect(ctx context.Context) error      { return nil }
+func (f *fakeWebSocketManager) SetMessageHandler(handler func([]byte)) {}
+func (f *fakeWebSocketManager) Close() error                           { return nil }
+func (f *fakeWebSocketManager) IsConnected() bool                      { return true }
+
+func (f *fakeWebSocketManager) SendMessage(message interface{}) error {
+	request := message.(map[string]interface{})
+	f.mu.Lock()
+	f.requests = append(f.requests, request)
+	f.mu.Unlock()
+	f.sent <- request["echo"].(string)
+	return nil
+}
+
+func newTestAPIClient() (*OneBotAPIClient, *fakeWebSocketManager) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	ws := &fakeWebSocketManager{sent: make(chan string, 1)}
+	return NewOneBotAPIClient(ws, logger), ws
+}
+
+func TestCallActionCorrelatesEcho(t *testing.T) {
+	client, ws := newTestAPIClient()
+
+	result := make(chan *types.OneBotResponse, 1)
+	go func() {
+		response, err := client.CallAction(context.Background(), "get_image", map[string]interface{}{"file": "a"})
+		if err != nil {
+			t.Errorf("CallAction() error = %v", err)
+		}
+		result <- response
+	}()
+
+	echo := <-ws.sent
+	if client.HandleResponse(&types.OneBotResponse{Status: "ok", Echo: "other"}) {
+		t.Error("HandleResponse() matched an unknown echo")
+	}
+	if !client.HandleResponse(&types.OneBotResponse{Status: "ok", Echo: echo, Data: "first"}) {
+		t.Fatal("HandleResponse() did not match the pending echo")
+	}
+
+	select {
+	case response := <-result:
+		if response.Data != "first" {
+			t.Errorf("response.Data = %v, want first", response.Data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("CallAction() did not return")
+	}
+}
+
+func TestHandleResponseDuplicateEchoDoesNotBlock(t *testing.T) {
+	client, ws := newTestAPIClient()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go client.CallAction(ctx, "get_image", nil)
+	echo := <-ws.sent
+
+	done := make(chan struct{})
+	go func() {
+		// 调用方尚未取走第一条响应时，重复的echo不能阻塞读取协程
+		client.HandleResponse(&types.OneBotResponse{Status: "ok", Echo: echo})
+		client.HandleResponse(&types.OneBotResponse{Status: "ok", Echo: echo})
+		client.HandleResponse(&types.OneBotResponse{Status: "ok", Echo: echo})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("HandleResponse() blocked on a duplicated echo")
+	}
+}
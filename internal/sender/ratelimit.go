@@ -0,0 +1,181 @@
+package sender
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"grunichat-onebot-adapter/internal/config"
+	"grunichat-onebot-adapter/internal/i18n"
+	"grunichat-onebot-adapter/internal/ratelimit"
+)
+
+// 单个群的待发送队列
+type groupQueue struct {
+	bucket   *ratelimit.TokenBucket
+	messages []string
+	firstAt  time.Time // 队列中最早一条消息的入队时间
+	dropped  int       // 队列已满时丢弃的消息数
+}
+
+// 带限流与合并功能的消息发送器，包装另一个发送器
+type RateLimitedSender struct {
+	next      IMessageSender
+	config    *config.Config
+	logger    *logrus.Logger
+	catalog   *i18n.Catalog
+	botBucket *ratelimit.TokenBucket
+	mu        sync.Mutex
+	queues    map[int64]*groupQueue
+	notify    chan struct{}
+}
+
+// 创建限流发送器
+func NewRateLimitedSender(next IMessageSender, cfg *config.Config, logger *logrus.Logger, catalog *i18n.Catalog) *RateLimitedSender {
+	return &RateLimitedSender{
+		next:      next,
+		config:    cfg,
+		logger:    logger,
+		catalog:   catalog,
+		botBucket: ratelimit.NewTokenBucket(cfg.RateLimit.BotPerMinute, cfg.RateLimit.BotBurst),
+		queues:    make(map[int64]*groupQueue),
+		notify:    make(chan struct{}, 1),
+	}
+}
+
+// 将群消息加入发送队列
+func (s *RateLimitedSender) SendGroupMessage(groupID int64, message string) {
+	s.mu.Lock()
+	queue, exists := s.queues[groupID]
+	if !exists {
+		queue = &groupQueue{
+			bucket: ratelimit.NewTokenBucket(s.config.RateLimit.GroupPerMinute, s.config.RateLimit.GroupBurst),
+		}
+		s.queues[groupID] = queue
+	}
+
+	// 队列已满：合并模式下丢弃最早的消息并计入汇总条数，非合并模式下丢弃新消息
+	// 丢弃数量统一在 flush 时汇总记录日志
+	if len(queue.messages) >= s.config.RateLimit.QueueSize {
+		queue.dropped++
+		if !s.coalescing() {
+			s.mu.Unlock()
+			return
+		}
+		queue.messages = queue.messages[1:]
+	}
+
+	if len(queue.messages) == 0 {
+		queue.firstAt = time.Now()
+	}
+	queue.messages = append(queue.messages, message)
+	s.mu.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// 获取所有群待发送消息总数
+func (s *RateLimitedSender) PendingCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, queue := range s.queues {
+		count += len(queue.messages)
+	}
+	return count
+}
+
+// 运行发送循环，直到上下文取消
+func (s *RateLimitedSender) Run(ctx context.Context) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.notify:
+		}
+		s.flush()
+	}
+}
+
+// 是否启用了合并模式
+func (s *RateLimitedSender) coalescing() bool {
+	return s.config.RateLimit.CoalesceWindowMs > 0
+}
+
+// 发送所有已就绪且有令牌的消息
+func (s *RateLimitedSender) flush() {
+	type outgoing struct {
+		groupID int64
+		message string
+	}
+	var ready []outgoing
+
+	window := time.Duration(s.config.RateLimit.CoalesceWindowMs) * time.Millisecond
+
+	s.mu.Lock()
+	for groupID, queue := range s.queues {
+		for len(queue.messages) > 0 {
+			// 合并模式下等待窗口结束
+			if s.coalescing() && time.Since(queue.firstAt) < window {
+				break
+			}
+			if !queue.bucket.Available() || !s.botBucket.Allow() {
+				break
+			}
+			queue.bucket.Allow()
+
+			var message string
+			if s.coalescing() {
+				message = s.coalesce(groupID, queue.messages, queue.dropped)
+				queue.messages = nil
+			} else {
+				message = queue.messages[0]
+				queue.messages = queue.messages[1:]
+			}
+			queue.firstAt = time.Now()
+			ready = append(ready, outgoing{groupID: groupID, message: message})
+		}
+
+		// 合并模式下丢弃数会计入下一条合并消息，等到合并消息发出后再记录
+		if queue.dropped > 0 && (!s.coalescing() || len(queue.messages) == 0) {
+			s.logger.Warnf("Dropped %d messages for group %d due to rate limiting", queue.dropped, groupID)
+			queue.dropped = 0
+		}
+	}
+	s.mu.Unlock()
+
+	for _, out := range ready {
+		s.next.SendGroupMessage(out.groupID, out.message)
+	}
+}
+
+// 将多条消息合并为一条，超出最大行数的部分与因队列已满丢弃的消息一起汇总显示
+func (s *RateLimitedSender) coalesce(groupID int64, messages []string, dropped int) string {
+	maxLines := s.config.RateLimit.CoalesceMaxLines
+	overflow := dropped
+	if len(messages) > maxLines {
+		overflow += len(messages) - maxLines
+		messages = messages[:maxLines]
+	}
+	if overflow == 0 {
+		return strings.Join(messages, "\n")
+	}
+
+	lines := append([]string{}, messages...)
+	lines = append(lines, s.catalog.Text(groupID, i18n.KeyRateLimitOverflow, map[string]string{
+		"count": fmt.Sprintf("%d", overflow),
+	}))
+	return strings.Join(lines, "\n")
+}
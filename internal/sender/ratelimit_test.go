@@ -0,0 +1,153 @@
+package sender
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"grunichat-onebot-adapter/internal/config"
+	"grunichat-onebot-adapter/internal/i18n"
+)
+
+// 记录发出消息的测试发送器
+type recordingSender struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (r *recordingSender) SendGroupMessage(groupID int64, message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, message)
+}
+
+func (r *recordingSender) sent() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.messages...)
+}
+
+// 按 rate_limit 配置段创建限流发送器，配置经过与配置文件相同的默认值处理
+func newTestRateLimitedSender(t *testing.T, rateLimit string) (*RateLimitedSender, *recordingSender) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("rate_limit:\n  enabled: true\n"+rateLimit), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	next := &recordingSender{}
+	return NewRateLimitedSender(next, cfg, logger, i18n.NewCatalog(cfg, logger)), next
+}
+
+func TestRateLimitedSenderDropsNewMessagesWhenQueueFull(t *testing.T) {
+	s, next := newTestRateLimitedSender(t, `
+  group_per_minute: 1
+  group_burst: 1
+  queue_size: 3
+`)
+
+	for _, message := range []string{"a", "b", "c", "d", "e"} {
+		s.SendGroupMessage(100, message)
+	}
+	if got := s.PendingCount(); got != 3 {
+		t.Fatalf("PendingCount() = %d, want 3", got)
+	}
+
+	s.flush()
+	if got := next.sent(); len(got) != 1 || got[0] != "a" {
+		t.Fatalf("sent %q, want only the first message", got)
+	}
+}
+
+func TestRateLimitedSenderBoundsQueueWhenCoalescing(t *testing.T) {
+	s, next := newTestRateLimitedSender(t, `
+  queue_size: 3
+  coalesce_window_ms: 1
+  coalesce_max_lines: 10
+`)
+
+	for _, message := range []string{"a", "b", "c", "d", "e"} {
+		s.SendGroupMessage(100, message)
+	}
+	if got := s.PendingCount(); got != 3 {
+		t.Fatalf("PendingCount() = %d, want 3", got)
+	}
+
+	s.mu.Lock()
+	s.queues[100].firstAt = s.queues[100].firstAt.Add(-10 * time.Millisecond)
+	s.mu.Unlock()
+	s.flush()
+
+	got := next.sent()
+	if len(got) != 1 {
+		t.Fatalf("sent %d messages, want 1 coalesced message", len(got))
+	}
+	lines := strings.Split(got[0], "\n")
+	if len(lines) != 4 || lines[0] != "c" || lines[2] != "e" || !strings.Contains(lines[3], "2") {
+		t.Fatalf("coalesced message = %q, want the newest three plus a summary of 2 dropped", got[0])
+	}
+}
+
+func TestRateLimitedSenderCoalesceOverflowSummary(t *testing.T) {
+	s, _ := newTestRateLimitedSender(t, `
+  coalesce_window_ms: 1
+  coalesce_max_lines: 2
+`)
+
+	message := s.coalesce(100, []string{"a", "b", "c", "d"}, 0)
+	lines := strings.Split(message, "\n")
+	if len(lines) != 3 || lines[0] != "a" || lines[1] != "b" || !strings.Contains(lines[2], "2") {
+		t.Fatalf("coalesce() = %q, want two lines plus a summary of 2", message)
+	}
+	if got := s.coalesce(100, []string{"a", "b"}, 0); got != "a\nb" {
+		t.Fatalf("coalesce() = %q, want no summary", got)
+	}
+}
+
+func TestRateLimitedSenderNonPositiveQueueSize(t *testing.T) {
+	for _, queueSize := range []string{"0", "-1"} {
+		s, next := newTestRateLimitedSender(t, `
+  queue_size: `+queueSize+`
+  group_burst: -2
+  coalesce_window_ms: 1
+`)
+		if s.config.RateLimit.QueueSize <= 0 || s.config.RateLimit.GroupBurst <= 0 {
+			t.Fatalf("queue_size %s: loaded %+v, want positive defaults", queueSize, s.config.RateLimit)
+		}
+
+		// 合并模式下队列已满时丢弃最早的消息，不能在空队列上切片
+		s.SendGroupMessage(100, "a")
+		s.mu.Lock()
+		s.queues[100].firstAt = s.queues[100].firstAt.Add(-10 * time.Millisecond)
+		s.mu.Unlock()
+		s.flush()
+		if got := next.sent(); len(got) != 1 || got[0] != "a" {
+			t.Errorf("queue_size %s: sent %q, want [a]", queueSize, got)
+		}
+	}
+}
+
+func TestRateLimitedSenderNegativeCoalesceMaxLines(t *testing.T) {
+	s, _ := newTestRateLimitedSender(t, `
+  coalesce_window_ms: 1
+  coalesce_max_lines: -1
+`)
+	if got := s.config.RateLimit.CoalesceMaxLines; got <= 0 {
+		t.Fatalf("coalesce_max_lines = %d, want a positive default", got)
+	}
+	if got := s.coalesce(100, []string{"a", "b"}, 0); got != "a\nb" {
+		t.Errorf("coalesce() = %q, want a\\nb", got)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 
+	"grunichat-onebot-adapter/internal/metrics"
 	"grunichat-onebot-adapter/internal/websocket"
 )
 
@@ -30,6 +31,7 @@ func NewOneBotMessageSender(wsManager websocket.IWebSocketManager, logger *logru
 func (s *OneBotMessageSender) SendGroupMessage(groupID int64, message string) {
 	if !s.wsManager.IsConnected() {
 		s.logger.Warn("OneBot WebSocket not connected, cannot send message")
+		metrics.SendFailuresTotal.Inc("onebot")
 		return
 	}
 
@@ -44,6 +46,7 @@ func (s *OneBotMessageSender) SendGroupMessage(groupID int64, message string) {
 
 	if err := s.wsManager.SendMessage(onebotMsg); err != nil {
 		s.logger.Errorf("Failed to send group message: %v", err)
+		metrics.SendFailuresTotal.Inc("onebot")
 	} else {
 		s.logger.Debugf("Sent group message to %d: %s", groupID, message)
 	}
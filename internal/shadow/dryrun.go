@@ -0,0 +1,58 @@
+package shadow
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"grunichat-onebot-adapter/internal/recorder"
+	"grunichat-onebot-adapter/internal/websocket"
+)
+
+// 演练模式的连接包装：收到的消息照常处理，发送的消息只写日志和记录，不实际发送
+type DryRunManager struct {
+	websocket.IWebSocketManager
+	connection string
+	sink       recorder.IFrameSink // 可为nil
+	logger     *logrus.Logger
+}
+
+// 创建演练模式的连接包装，sink 为nil时只写日志
+func NewDryRunManager(manager websocket.IWebSocketManager, connection string, sink recorder.IFrameSink, logger *logrus.Logger) *DryRunManager {
+	return &DryRunManager{
+		IWebSocketManager: manager,
+		connection:        connection,
+		sink:              sink,
+		logger:            logger,
+	}
+}
+
+// 拦截发送的消息，只读的OneBot API请求照常发送
+func (m *DryRunManager) SendMessage(message interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	if m.connection == recorder.ConnectionOneBot && isReadOnlyAction(data) {
+		return m.IWebSocketManager.SendMessage(message)
+	}
+
+	m.logger.Infof("[dry-run] Would send to %s: %s", m.connection, data)
+	if m.sink != nil {
+		m.sink.Record(m.connection, recorder.DirectionSent, data)
+	}
+	return nil
+}
+
+// 是否为只读的OneBot API请求（get_*、can_*），例如获取图片信息
+func isReadOnlyAction(data []byte) bool {
+	var request struct {
+		Action string `json:"action"`
+	}
+	if json.Unmarshal(data, &request) != nil {
+		return false
+	}
+	return strings.HasPrefix(request.Action, "get_") || strings.HasPrefix(request.Action, "can_")
+}
@@ -0,0 +1,221 @@
+package shadow
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	gorillaws "github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+
+	"grunichat-onebot-adapter/internal/recorder"
+	"grunichat-onebot-adapter/internal/websocket"
+)
+
+// 镜像连接断开后的重连间隔
+const mirrorReconnectDelay = 5 * time.Second
+
+// 生产实例一侧的镜像客户端，将收到的帧转发给影子实例；影子实例不可用时丢弃，不影响正常转发
+type MirrorClient struct {
+	url    string
+	token  string
+	logger *logrus.Logger
+	frames chan recorder.Frame
+}
+
+// 创建镜像客户端
+func NewMirrorClient(url, token string, queueSize int, logger *logrus.Logger) *MirrorClient {
+	return &MirrorClient{
+		url:    url,
+		token:  token,
+		logger: logger,
+		frames: make(chan recorder.Frame, queueSize),
+	}
+}
+
+// 加入待镜像的帧，只镜像收到的帧
+func (c *MirrorClient) Record(connection, direction string, data []byte) {
+	if direction != recorder.DirectionReceived {
+		return
+	}
+
+	frame := recorder.Frame{
+		Time:       time.Now(),
+		Connection: connection,
+		Direction:  direction,
+		Data:       append([]byte(nil), data...),
+	}
+	select {
+	case c.frames <- frame:
+	default:
+		c.logger.Debugf("Mirror queue is full, dropping %s frame", connection)
+	}
+}
+
+// 保持到影子实例的连接并发送镜像帧，直到上下文取消
+func (c *MirrorClient) Run(ctx context.Context) {
+	for {
+		conn, err := c.dial(ctx)
+		if err != nil {
+			c.logger.Warnf("Failed to connect to shadow instance: %v", err)
+		} else {
+			c.logger.Infof("Mirroring traffic to shadow instance at %s", c.url)
+			c.pump(ctx, conn)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(mirrorReconnectDelay):
+		}
+	}
+}
+
+// 连接影子实例
+func (c *MirrorClient) dial(ctx context.Context) (*gorillaws.Conn, error) {
+	dialer := gorillaws.Dialer{HandshakeTimeout: 10 * time.Second}
+	headers := http.Header{}
+	if c.token != "" {
+		headers.Set("Authorization", "Bearer "+c.token)
+	}
+
+	conn, _, err := dialer.DialContext(ctx, c.url, headers)
+	return conn, err
+}
+
+// 发送镜像帧，连接出错或上下文取消时返回
+func (c *MirrorClient) pump(ctx context.Context, conn *gorillaws.Conn) {
+	defer conn.Close()
+
+	// 读取协程用于处理控制帧并发现连接断开
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-closed:
+			c.logger.Warn("Shadow instance disconnected")
+			return
+		case frame := <-c.frames:
+			if err := conn.WriteJSON(frame); err != nil {
+				c.logger.Warnf("Failed to mirror frame to shadow instance: %v", err)
+				return
+			}
+		}
+	}
+}
+
+// 影子实例一侧的镜像来源，代替真实的OneBot和GRUniChat连接
+type MirrorSource struct {
+	logger    *logrus.Logger
+	upgrader  gorillaws.Upgrader
+	onebot    *mirrorConnection
+	grunichat *mirrorConnection
+}
+
+// 创建镜像来源
+func NewMirrorSource(logger *logrus.Logger) *MirrorSource {
+	return &MirrorSource{
+		logger:    logger,
+		onebot:    &mirrorConnection{name: recorder.ConnectionOneBot},
+		grunichat: &mirrorConnection{name: recorder.ConnectionGRUniChat},
+	}
+}
+
+// 代替OneBot连接，收到的是生产实例镜像的OneBot帧
+func (s *MirrorSource) OneBot() websocket.IWebSocketManager {
+	return s.onebot
+}
+
+// 代替GRUniChat连接，收到的是生产实例镜像的GRUniChat帧
+func (s *MirrorSource) GRUniChat() websocket.IWebSocketManager {
+	return s.grunichat
+}
+
+// 接收生产实例镜像连接的处理器
+func (s *MirrorSource) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		conn, err := s.upgrader.Upgrade(w, req, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		s.logger.Infof("Production instance connected for mirroring from %s", req.RemoteAddr)
+		for {
+			var frame recorder.Frame
+			if err := conn.ReadJSON(&frame); err != nil {
+				s.logger.Infof("Production instance mirror disconnected: %v", err)
+				return
+			}
+
+			switch frame.Connection {
+			case recorder.ConnectionOneBot:
+				s.onebot.deliver(frame.Data)
+			case recorder.ConnectionGRUniChat:
+				s.grunichat.deliver(frame.Data)
+			}
+		}
+	})
+}
+
+// 由镜像帧驱动的连接，不能发送消息
+type mirrorConnection struct {
+	name      string
+	mu        sync.Mutex
+	handler   func(message []byte)
+	connected bool
+}
+
+func (c *mirrorConnection) Connect(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connected = true
+	return nil
+}
+
+func (c *mirrorConnection) SendMessage(message interface{}) error {
+	return fmt.Errorf("%s mirror connection is read-only", c.name)
+}
+
+func (c *mirrorConnection) SetMessageHandler(handler func(message []byte)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handler = handler
+}
+
+func (c *mirrorConnection) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connected = false
+	return nil
+}
+
+func (c *mirrorConnection) IsConnected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connected
+}
+
+// 将镜像帧交给消息处理器
+func (c *mirrorConnection) deliver(data []byte) {
+	c.mu.Lock()
+	handler := c.handler
+	connected := c.connected
+	c.mu.Unlock()
+
+	if connected && handler != nil {
+		handler(data)
+	}
+}
@@ -0,0 +1,164 @@
+package shadow
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"grunichat-onebot-adapter/internal/recorder"
+)
+
+func newTestLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+// 记录实际发送内容的测试连接
+type sendRecorder struct {
+	sent []interface{}
+}
+
+func (m *sendRecorder) Connect(ctx context.Context) error              { return nil }
+func (m *sendRecorder) SetMessageHandler(handler func(message []byte)) {}
+func (m *sendRecorder) Close() error                                   { return nil }
+func (m *sendRecorder) IsConnected() bool                              { return true }
+
+func (m *sendRecorder) SendMessage(message interface{}) error {
+	m.sent = append(m.sent, message)
+	return nil
+}
+
+// 在内存中收集帧的接收方
+type frameSink struct {
+	frames []string
+}
+
+func (s *frameSink) Record(connection, direction string, data []byte) {
+	s.frames = append(s.frames, connection+" "+direction+" "+string(data))
+}
+
+func TestDryRunManagerSwallowsSends(t *testing.T) {
+	tests := []struct {
+		name       string
+		connection string
+		message    map[string]interface{}
+		wantSent   bool
+	}{
+		{"get action", recorder.ConnectionOneBot, map[string]interface{}{"action": "get_image"}, true},
+		{"can action", recorder.ConnectionOneBot, map[string]interface{}{"action": "can_send_image"}, true},
+		{"send action", recorder.ConnectionOneBot, map[string]interface{}{"action": "send_group_msg"}, false},
+		{"set action", recorder.ConnectionOneBot, map[string]interface{}{"action": "set_group_ban"}, false},
+		{"grunichat frame", recorder.ConnectionGRUniChat, map[string]interface{}{"action": "get_image", "type": "chat"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &sendRecorder{}
+			sink := &frameSink{}
+			manager := NewDryRunManager(inner, tt.connection, sink, newTestLogger())
+
+			if err := manager.SendMessage(tt.message); err != nil {
+				t.Fatal(err)
+			}
+			if sent := len(inner.sent) == 1; sent != tt.wantSent {
+				t.Errorf("sent = %v, want %v", sent, tt.wantSent)
+			}
+			// 被拦截的帧写入记录
+			if recorded := len(sink.frames) == 1; recorded == tt.wantSent {
+				t.Errorf("recorded frames = %q, want recorded %v", sink.frames, !tt.wantSent)
+			}
+		})
+	}
+}
+
+func TestDryRunManagerWithoutSink(t *testing.T) {
+	inner := &sendRecorder{}
+	manager := NewDryRunManager(inner, recorder.ConnectionOneBot, nil, newTestLogger())
+
+	if err := manager.SendMessage(map[string]interface{}{"action": "send_group_msg"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(inner.sent) != 0 {
+		t.Errorf("sent %v in dry-run mode", inner.sent)
+	}
+}
+
+// 收集镜像连接收到的帧
+type deliveries struct {
+	mu     sync.Mutex
+	frames []string
+}
+
+func (d *deliveries) handle(message []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.frames = append(d.frames, string(message))
+}
+
+func (d *deliveries) get() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string{}, d.frames...)
+}
+
+func TestMirrorSourceReceivesMirroredFrames(t *testing.T) {
+	source := NewMirrorSource(newTestLogger())
+	server := httptest.NewServer(source.Handler())
+	defer server.Close()
+
+	var onebot, grunichat deliveries
+	source.OneBot().SetMessageHandler(onebot.handle)
+	source.GRUniChat().SetMessageHandler(grunichat.handle)
+	source.OneBot().Connect(context.Background())
+	source.GRUniChat().Connect(context.Background())
+
+	client := NewMirrorClient("ws"+strings.TrimPrefix(server.URL, "http"), "token", 10, newTestLogger())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go client.Run(ctx)
+
+	client.Record(recorder.ConnectionOneBot, recorder.DirectionReceived, []byte(`{"post_type":"message"}`))
+	client.Record(recorder.ConnectionOneBot, recorder.DirectionSent, []byte(`{"action":"send_group_msg"}`)) // 发送的帧不镜像
+	client.Record(recorder.ConnectionGRUniChat, recorder.DirectionReceived, []byte(`{"type":"chat"}`))
+
+	deadline := time.Now().Add(3 * time.Second)
+	for len(onebot.get()) < 1 || len(grunichat.get()) < 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("mirrored frames not delivered: onebot %q grunichat %q", onebot.get(), grunichat.get())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if got := onebot.get(); len(got) != 1 || got[0] != `{"post_type":"message"}` {
+		t.Errorf("onebot frames = %q", got)
+	}
+	if got := grunichat.get(); len(got) != 1 || got[0] != `{"type":"chat"}` {
+		t.Errorf("grunichat frames = %q", got)
+	}
+	if err := source.OneBot().SendMessage(map[string]string{"action": "send_group_msg"}); err == nil {
+		t.Error("SendMessage() on a mirror connection succeeded")
+	}
+}
+
+func TestMirrorConnectionDropsFramesWhenClosed(t *testing.T) {
+	source := NewMirrorSource(newTestLogger())
+	var received deliveries
+	source.OneBot().SetMessageHandler(received.handle)
+
+	source.onebot.deliver([]byte(`{}`))
+	source.OneBot().Connect(context.Background())
+	source.onebot.deliver([]byte(`{"n":1}`))
+	source.OneBot().Close()
+	source.onebot.deliver([]byte(`{}`))
+
+	if got := received.get(); len(got) != 1 || got[0] != `{"n":1}` {
+		t.Errorf("delivered %q, want only the frame received while connected", got)
+	}
+}
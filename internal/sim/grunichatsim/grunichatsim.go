@@ -0,0 +1,434 @@
+// Package grunichatsim 提供进程内的GRUniChat WebSocket模拟服务端，供回放模式和 go test 中的端到端测试使用
+package grunichatsim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"grunichat-onebot-adapter/internal/types"
+	adapterws "grunichat-onebot-adapter/internal/websocket"
+)
+
+// 默认的服务端ID，用作下发消息的 from
+const DefaultServerID = "grunichat_sim"
+
+// 等待hello消息的超时时间
+const helloTimeout = 5 * time.Second
+
+// 收到的一条消息
+type Message struct {
+	types.GRUniChatMessage
+	ClientID   string          // 发送消息的连接在hello中声明的客户端ID
+	Raw        json.RawMessage // 原始帧
+	ReceivedAt time.Time
+}
+
+// 模拟的GRUniChat WebSocket服务端
+type Server struct {
+	ServerID string
+
+	httpServer *httptest.Server
+	upgrader   websocket.Upgrader
+
+	mu               sync.Mutex
+	expectedClientID string // 非空时只接受该客户端ID的hello
+	authMode         string // 要求的认证方式，为空时不认证
+	authToken        string
+	helloAck         bool // 握手结果以 hello_ack / hello_reject 回复
+	clients          map[*client]struct{}
+	hellos           []map[string]interface{}
+	helloErrors      []error
+	messages         []*Message
+	changed          chan struct{} // 有新连接、hello或新消息时关闭并重建，用于等待
+}
+
+// 一个完成hello握手的客户端连接，写操作需要加锁
+type client struct {
+	id      string
+	ws      *websocket.Conn
+	writeMu sync.Mutex
+}
+
+func (c *client) writeJSON(v interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.ws.WriteJSON(v)
+}
+
+// 创建并启动模拟服务端
+func NewServer() *Server {
+	s := &Server{
+		ServerID: DefaultServerID,
+		clients:  make(map[*client]struct{}),
+		changed:  make(chan struct{}),
+	}
+	s.httpServer = httptest.NewServer(http.HandlerFunc(s.serveWS))
+	return s
+}
+
+// WebSocket地址，用于 grunichat.url
+func (s *Server) URL() string {
+	return "ws" + strings.TrimPrefix(s.httpServer.URL, "http") + "/ws"
+}
+
+// 只接受指定客户端ID的hello，为空时接受任意非空ID
+func (s *Server) ExpectClientID(clientID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expectedClientID = clientID
+}
+
+// 要求客户端认证，mode 与 grunichat.auth.mode 相同（token、hmac、bearer），token 为令牌或签名密钥；
+// 要求认证时握手结果总是以 hello_ack / hello_reject 回复
+func (s *Server) RequireAuth(mode, token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authMode = mode
+	s.authToken = token
+	if mode != "" && mode != adapterws.AuthNone {
+		s.helloAck = true
+	}
+}
+
+// 不要求认证时是否也回复 hello_ack / hello_reject，默认不回复（与不支持认证的服务端相同）
+func (s *Server) SetHelloAck(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.helloAck = enabled
+}
+
+// 记录握手失败
+func (s *Server) recordHelloError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.helloErrors = append(s.helloErrors, err)
+	s.notifyLocked()
+}
+
+// 处理WebSocket连接
+func (s *Server) serveWS(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	authMode, authToken, helloAck := s.authMode, s.authToken, s.helloAck
+	s.mu.Unlock()
+
+	// bearer方式在升级前校验请求头
+	if authMode == adapterws.AuthBearer && req.Header.Get("Authorization") != "Bearer "+authToken {
+		s.recordHelloError(fmt.Errorf("missing or invalid bearer token"))
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ws, err := s.upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+
+	c, err := s.handshake(ws, authMode, authToken)
+	if err != nil {
+		s.recordHelloError(err)
+
+		if helloAck {
+			ws.WriteJSON(&types.GRUniChatHandshake{Type: adapterws.HandshakeReject, Reason: err.Error()})
+		}
+		ws.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.ClosePolicyViolation, err.Error()),
+			time.Now().Add(time.Second))
+		return
+	}
+
+	if helloAck {
+		if err := c.writeJSON(&types.GRUniChatHandshake{Type: adapterws.HandshakeAck}); err != nil {
+			return
+		}
+	}
+
+	s.mu.Lock()
+	s.clients[c] = struct{}{}
+	s.notifyLocked()
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, c)
+		s.notifyLocked()
+		s.mu.Unlock()
+	}()
+
+	for {
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		s.record(c, data)
+	}
+}
+
+// 校验第一帧是否为hello消息，并按认证方式校验令牌或签名
+func (s *Server) handshake(ws *websocket.Conn, authMode, authToken string) (*client, error) {
+	// hmac方式先下发随机数
+	nonce := ""
+	if authMode == adapterws.AuthHMAC {
+		nonce = uuid.New().String()
+		challenge := &types.GRUniChatHandshake{Type: adapterws.HandshakeChallenge, Nonce: nonce}
+		if err := ws.WriteJSON(challenge); err != nil {
+			return nil, fmt.Errorf("failed to send challenge: %w", err)
+		}
+	}
+
+	ws.SetReadDeadline(time.Now().Add(helloTimeout))
+	_, data, err := ws.ReadMessage()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hello message: %w", err)
+	}
+	ws.SetReadDeadline(time.Time{})
+
+	var hello map[string]interface{}
+	if err := json.Unmarshal(data, &hello); err != nil {
+		return nil, fmt.Errorf("invalid hello message: %w", err)
+	}
+
+	s.mu.Lock()
+	s.hellos = append(s.hellos, hello)
+	expected := s.expectedClientID
+	s.mu.Unlock()
+
+	if msgType, _ := hello["type"].(string); msgType != "hello" {
+		return nil, fmt.Errorf("expected hello message, got type %q", msgType)
+	}
+	from, _ := hello["from"].(string)
+	if from == "" {
+		return nil, fmt.Errorf("hello message has no client ID")
+	}
+	if expected != "" && from != expected {
+		return nil, fmt.Errorf("unexpected client ID %q (expected %q)", from, expected)
+	}
+
+	switch authMode {
+	case adapterws.AuthToken:
+		if token, _ := hello["token"].(string); token != authToken {
+			return nil, fmt.Errorf("invalid token")
+		}
+	case adapterws.AuthHMAC:
+		signature, _ := hello["signature"].(string)
+		if helloNonce, _ := hello["nonce"].(string); helloNonce != nonce || signature != adapterws.SignNonce(authToken, from, nonce) {
+			return nil, fmt.Errorf("invalid signature")
+		}
+	}
+
+	return &client{id: from, ws: ws}, nil
+}
+
+// 记录收到的消息
+func (s *Server) record(c *client, data []byte) {
+	msg := &Message{ClientID: c.id, Raw: append(json.RawMessage(nil), data...), ReceivedAt: time.Now()}
+	if err := json.Unmarshal(data, &msg.GRUniChatMessage); err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, msg)
+	s.notifyLocked()
+}
+
+// 唤醒等待者（调用方需持有锁）
+func (s *Server) notifyLocked() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// 等待条件满足，超时返回错误
+func (s *Server) waitFor(timeout time.Duration, what string, done func() bool) error {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		s.mu.Lock()
+		ok := done()
+		changed := s.changed
+		s.mu.Unlock()
+		if ok {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return fmt.Errorf("timed out after %v waiting for %s", timeout, what)
+		}
+	}
+}
+
+// 等待客户端完成hello握手
+func (s *Server) WaitForClient(timeout time.Duration) error {
+	return s.waitFor(timeout, "GRUniChat client hello", func() bool {
+		return len(s.clients) > 0
+	})
+}
+
+// 已连接客户端的ID
+func (s *Server) ClientIDs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]string, 0, len(s.clients))
+	for c := range s.clients {
+		ids = append(ids, c.id)
+	}
+	return ids
+}
+
+// 收到的所有hello消息（包括被拒绝的）
+func (s *Server) Hellos() []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]map[string]interface{}(nil), s.hellos...)
+}
+
+// 握手失败的原因
+func (s *Server) HelloErrors() []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]error(nil), s.helloErrors...)
+}
+
+// 收到的所有消息（不含hello）
+func (s *Server) Messages() []*Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Message(nil), s.messages...)
+}
+
+// 收到的指定类型的消息（chat, command, event）
+func (s *Server) MessagesOfType(msgType string) []*Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.messagesOfTypeLocked(msgType)
+}
+
+func (s *Server) messagesOfTypeLocked(msgType string) []*Message {
+	var matched []*Message
+	for _, msg := range s.messages {
+		if msg.Type == msgType {
+			matched = append(matched, msg)
+		}
+	}
+	return matched
+}
+
+// 等待收到至少 count 条指定类型的消息，返回前 count 条
+func (s *Server) WaitForMessages(msgType string, count int, timeout time.Duration) ([]*Message, error) {
+	var matched []*Message
+	err := s.waitFor(timeout, fmt.Sprintf("%d %s message(s)", count, msgType), func() bool {
+		matched = s.messagesOfTypeLocked(msgType)
+		return len(matched) >= count
+	})
+	if err != nil {
+		return matched, err
+	}
+	return matched[:count], nil
+}
+
+// 等待下一条指定类型的消息（只计算调用本方法之后收到的）
+func (s *Server) WaitForNextMessage(msgType string, timeout time.Duration) (*Message, error) {
+	existing := len(s.MessagesOfType(msgType))
+	messages, err := s.WaitForMessages(msgType, existing+1, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return messages[existing], nil
+}
+
+// 清空已记录的消息
+func (s *Server) ResetMessages() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+}
+
+// 向所有客户端发送消息，未设置的 from、totalId、currentTime 会自动填充
+func (s *Server) Send(msg *types.GRUniChatMessage) error {
+	if msg.From == "" {
+		msg.From = s.ServerID
+	}
+	if msg.TotalID == "" {
+		msg.TotalID = uuid.New().String()
+	}
+	if msg.CurrentTime == "" {
+		msg.CurrentTime = time.Now().Format("2006-01-02 15:04:05")
+	}
+	return s.SendRaw(msg)
+}
+
+// 向所有客户端发送任意帧
+func (s *Server) SendRaw(frame interface{}) error {
+	s.mu.Lock()
+	clients := make([]*client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.mu.Unlock()
+
+	if len(clients) == 0 {
+		return fmt.Errorf("no GRUniChat client connected")
+	}
+	for _, c := range clients {
+		if err := c.writeJSON(frame); err != nil {
+			return fmt.Errorf("failed to send message: %w", err)
+		}
+	}
+	return nil
+}
+
+// 发送聊天消息，executeAt 为空时广播到所有服务群聊，例如 "group_123456789"
+func (s *Server) SendChat(sender, text, executeAt string) error {
+	return s.Send(&types.GRUniChatMessage{
+		Type: "chat",
+		Body: types.GRUniChatBody{
+			Sender:      sender,
+			ChatMessage: text,
+			ExecuteAt:   executeAt,
+		},
+	})
+}
+
+// 发送事件消息
+func (s *Server) SendEvent(sender, detail, executeAt string) error {
+	return s.Send(&types.GRUniChatMessage{
+		Type: "event",
+		Body: types.GRUniChatBody{
+			Sender:      sender,
+			EventDetail: detail,
+			ExecuteAt:   executeAt,
+		},
+	})
+}
+
+// 断开所有客户端连接，用于测试离线缓存和重连
+func (s *Server) DisconnectAll() {
+	s.mu.Lock()
+	clients := make([]*client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.mu.Unlock()
+
+	for _, c := range clients {
+		c.ws.Close()
+	}
+}
+
+// 关闭服务端
+func (s *Server) Close() {
+	s.DisconnectAll()
+	s.httpServer.Close()
+}
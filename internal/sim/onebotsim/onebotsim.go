@@ -0,0 +1,435 @@
+// Package onebotsim 提供进程内的OneBot v11 WebSocket模拟服务端，供回放模式和 go test 中的端到端测试使用
+package onebotsim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"grunichat-onebot-adapter/internal/types"
+)
+
+// 默认的机器人QQ号
+const DefaultSelfID int64 = 10000
+
+// 收到的一次API调用
+type Action struct {
+	Action     string                 `json:"action"`
+	Params     map[string]interface{} `json:"params"`
+	Echo       string                 `json:"echo"`
+	Raw        json.RawMessage        `json:"-"`
+	ReceivedAt time.Time              `json:"-"`
+}
+
+// 群消息内容（params.message 为字符串时）
+func (a *Action) Message() string {
+	message, _ := a.Params["message"].(string)
+	return message
+}
+
+// 群号（params.group_id）
+func (a *Action) GroupID() int64 {
+	return paramInt64(a.Params, "group_id")
+}
+
+// 根据API调用生成响应，返回nil表示不响应
+type Responder func(action *Action) *types.OneBotResponse
+
+// 成功响应
+func OK(data interface{}) Responder {
+	return func(action *Action) *types.OneBotResponse {
+		return &types.OneBotResponse{Status: "ok", RetCode: 0, Data: data}
+	}
+}
+
+// 失败响应
+func Failed(retCode int) Responder {
+	return func(action *Action) *types.OneBotResponse {
+		return &types.OneBotResponse{Status: "failed", RetCode: retCode}
+	}
+}
+
+// 模拟的OneBot v11正向WebSocket服务端
+type Server struct {
+	SelfID int64
+
+	httpServer  *httptest.Server
+	upgrader    websocket.Upgrader
+	accessToken string
+
+	mu               sync.Mutex
+	conns            map[*conn]struct{}
+	actions          []*Action
+	responders       map[string]Responder
+	defaultResponder Responder
+	nextMessageID    int64
+	changed          chan struct{} // 有新连接或新API调用时关闭并重建，用于等待
+}
+
+// 一个客户端连接，写操作需要加锁
+type conn struct {
+	ws      *websocket.Conn
+	writeMu sync.Mutex
+}
+
+func (c *conn) writeJSON(v interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.ws.WriteJSON(v)
+}
+
+// 创建并启动模拟服务端，默认对所有API调用返回成功
+func NewServer() *Server {
+	s := &Server{
+		SelfID:        DefaultSelfID,
+		conns:         make(map[*conn]struct{}),
+		responders:    make(map[string]Responder),
+		nextMessageID: 1,
+		changed:       make(chan struct{}),
+	}
+	s.defaultResponder = s.respondOK
+	s.httpServer = httptest.NewServer(http.HandlerFunc(s.serveWS))
+	return s
+}
+
+// WebSocket地址，用于 onebot.websocket_url
+func (s *Server) URL() string {
+	return "ws" + strings.TrimPrefix(s.httpServer.URL, "http")
+}
+
+// 要求客户端携带 Authorization: Bearer <token>，为空时不校验
+func (s *Server) SetAccessToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accessToken = token
+}
+
+// 设置指定API的响应
+func (s *Server) OnAction(action string, responder Responder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responders[action] = responder
+}
+
+// 设置未单独配置的API的响应，nil表示不响应
+func (s *Server) SetDefaultResponder(responder Responder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.defaultResponder = responder
+}
+
+// 处理WebSocket连接
+func (s *Server) serveWS(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	token := s.accessToken
+	s.mu.Unlock()
+
+	if token != "" && req.Header.Get("Authorization") != "Bearer "+token {
+		http.Error(w, "invalid access token", http.StatusUnauthorized)
+		return
+	}
+
+	ws, err := s.upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		return
+	}
+
+	c := &conn{ws: ws}
+	s.mu.Lock()
+	s.conns[c] = struct{}{}
+	s.notifyLocked()
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.notifyLocked()
+		s.mu.Unlock()
+		ws.Close()
+	}()
+
+	for {
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		s.handleAction(c, data)
+	}
+}
+
+// 记录API调用并按配置响应
+func (s *Server) handleAction(c *conn, data []byte) {
+	action := &Action{Raw: append(json.RawMessage(nil), data...), ReceivedAt: time.Now()}
+	if err := json.Unmarshal(data, action); err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.actions = append(s.actions, action)
+	responder, exists := s.responders[action.Action]
+	if !exists {
+		responder = s.defaultResponder
+	}
+	s.notifyLocked()
+	s.mu.Unlock()
+
+	if responder == nil {
+		return
+	}
+	response := responder(action)
+	if response == nil {
+		return
+	}
+	response.Echo = action.Echo
+	c.writeJSON(response)
+}
+
+// 默认响应：返回成功，发送消息类API分配消息ID
+func (s *Server) respondOK(action *Action) *types.OneBotResponse {
+	if !strings.HasPrefix(action.Action, "send_") {
+		return &types.OneBotResponse{Status: "ok", RetCode: 0}
+	}
+
+	s.mu.Lock()
+	messageID := s.nextMessageID
+	s.nextMessageID++
+	s.mu.Unlock()
+	return &types.OneBotResponse{Status: "ok", RetCode: 0, Data: map[string]interface{}{"message_id": messageID}}
+}
+
+// 唤醒等待者（调用方需持有锁）
+func (s *Server) notifyLocked() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// 等待条件满足，超时返回错误
+func (s *Server) waitFor(timeout time.Duration, what string, done func() bool) error {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		s.mu.Lock()
+		ok := done()
+		changed := s.changed
+		s.mu.Unlock()
+		if ok {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return fmt.Errorf("timed out after %v waiting for %s", timeout, what)
+		}
+	}
+}
+
+// 等待客户端连接
+func (s *Server) WaitForConnection(timeout time.Duration) error {
+	return s.waitFor(timeout, "OneBot client connection", func() bool {
+		return len(s.conns) > 0
+	})
+}
+
+// 当前连接数
+func (s *Server) ConnectionCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// 收到的所有API调用
+func (s *Server) Actions() []*Action {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Action(nil), s.actions...)
+}
+
+// 收到的指定API调用
+func (s *Server) ActionsNamed(name string) []*Action {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matched []*Action
+	for _, action := range s.actions {
+		if action.Action == name {
+			matched = append(matched, action)
+		}
+	}
+	return matched
+}
+
+// 等待收到至少 count 次指定API调用，返回前 count 次
+func (s *Server) WaitForActions(name string, count int, timeout time.Duration) ([]*Action, error) {
+	var matched []*Action
+	err := s.waitFor(timeout, fmt.Sprintf("%d %s action(s)", count, name), func() bool {
+		matched = matched[:0]
+		for _, action := range s.actions {
+			if action.Action == name {
+				matched = append(matched, action)
+			}
+		}
+		return len(matched) >= count
+	})
+	if err != nil {
+		return matched, err
+	}
+	return matched[:count], nil
+}
+
+// 等待下一次指定API调用（只计算调用本方法之后收到的）
+func (s *Server) WaitForNextAction(name string, timeout time.Duration) (*Action, error) {
+	existing := len(s.ActionsNamed(name))
+	actions, err := s.WaitForActions(name, existing+1, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return actions[existing], nil
+}
+
+// 清空已记录的API调用
+func (s *Server) ResetActions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.actions = nil
+}
+
+// 向所有连接推送事件
+func (s *Server) PushEvent(event interface{}) error {
+	s.mu.Lock()
+	conns := make([]*conn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	if len(conns) == 0 {
+		return fmt.Errorf("no OneBot client connected")
+	}
+	for _, c := range conns {
+		if err := c.writeJSON(event); err != nil {
+			return fmt.Errorf("failed to push event: %w", err)
+		}
+	}
+	return nil
+}
+
+// 群消息事件参数
+type GroupMessage struct {
+	GroupID  int64
+	UserID   int64
+	Nickname string
+	Card     string
+	Role     string      // owner, admin, member，默认为 member
+	Message  interface{} // CQ码字符串或消息段数组
+}
+
+// 推送群消息事件，返回分配的消息ID
+func (s *Server) PushGroupMessage(msg GroupMessage) (int64, error) {
+	s.mu.Lock()
+	messageID := s.nextMessageID
+	s.nextMessageID++
+	s.mu.Unlock()
+
+	role := msg.Role
+	if role == "" {
+		role = "member"
+	}
+	raw, _ := msg.Message.(string)
+
+	event := &types.OneBotMessage{
+		PostType:    "message",
+		MessageType: "group",
+		SubType:     "normal",
+		MessageID:   messageID,
+		UserID:      msg.UserID,
+		GroupID:     msg.GroupID,
+		Message:     msg.Message,
+		RawMessage:  raw,
+		Sender: types.OneBotSender{
+			UserID:   msg.UserID,
+			Nickname: msg.Nickname,
+			Card:     msg.Card,
+			Role:     role,
+		},
+		Time:   time.Now().Unix(),
+		SelfID: s.SelfID,
+	}
+	return messageID, s.PushEvent(event)
+}
+
+// 推送通知事件，例如 PushNotice("group_increase", map[string]interface{}{"group_id": 1, "user_id": 2})
+func (s *Server) PushNotice(noticeType string, fields map[string]interface{}) error {
+	event := map[string]interface{}{
+		"post_type":   "notice",
+		"notice_type": noticeType,
+		"time":        time.Now().Unix(),
+		"self_id":     s.SelfID,
+	}
+	for key, value := range fields {
+		event[key] = value
+	}
+	return s.PushEvent(event)
+}
+
+// 推送心跳元事件
+func (s *Server) PushHeartbeat(online bool, interval time.Duration) error {
+	return s.PushEvent(&types.OneBotMetaEvent{
+		PostType:      "meta_event",
+		MetaEventType: "heartbeat",
+		Time:          time.Now().Unix(),
+		SelfID:        s.SelfID,
+		Interval:      interval.Milliseconds(),
+		Status:        &types.OneBotStatus{Online: online, Good: online},
+	})
+}
+
+// 推送生命周期元事件（enable, disable, connect）
+func (s *Server) PushLifecycle(subType string) error {
+	return s.PushEvent(&types.OneBotMetaEvent{
+		PostType:      "meta_event",
+		MetaEventType: "lifecycle",
+		SubType:       subType,
+		Time:          time.Now().Unix(),
+		SelfID:        s.SelfID,
+	})
+}
+
+// 断开所有客户端连接，用于测试重连
+func (s *Server) DisconnectAll() {
+	s.mu.Lock()
+	conns := make([]*conn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	for _, c := range conns {
+		c.ws.Close()
+	}
+}
+
+// 关闭服务端
+func (s *Server) Close() {
+	s.DisconnectAll()
+	s.httpServer.Close()
+}
+
+// 读取整数参数（JSON数字解码为float64）
+func paramInt64(params map[string]interface{}, key string) int64 {
+	switch value := params[key].(type) {
+	case float64:
+		return int64(value)
+	case json.Number:
+		n, _ := value.Int64()
+		return n
+	}
+	return 0
+}
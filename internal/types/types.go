@@ -18,6 +18,16 @@ type GRUniChatBody struct {
 	ExecuteAt   string `json:"executeAt,omitempty"`
 }
 
+// GRUniChat握手帧：客户端的hello，服务端的challenge、hello_ack、hello_reject
+type GRUniChatHandshake struct {
+	Type      string `json:"type"`
+	From      string `json:"from,omitempty"`
+	Token     string `json:"token,omitempty"`     // token认证方式的令牌
+	Nonce     string `json:"nonce,omitempty"`     // hmac认证方式中服务端下发的随机数
+	Signature string `json:"signature,omitempty"` // hmac认证方式中对随机数的签名
+	Reason    string `json:"reason,omitempty"`    // hello_reject 的拒绝原因
+}
+
 // OneBot v11消息结构体
 type OneBotMessage struct {
 	PostType    string                 `json:"post_type"`
@@ -65,3 +75,20 @@ type OneBotResponse struct {
 	Data    interface{} `json:"data,omitempty"`
 	Echo    string      `json:"echo,omitempty"`
 }
+
+// OneBot元事件结构体（心跳、生命周期）
+type OneBotMetaEvent struct {
+	PostType      string        `json:"post_type"`
+	MetaEventType string        `json:"meta_event_type"`    // heartbeat, lifecycle
+	SubType       string        `json:"sub_type,omitempty"` // lifecycle: enable, disable, connect
+	Time          int64         `json:"time,omitempty"`
+	SelfID        int64         `json:"self_id,omitempty"`
+	Interval      int64         `json:"interval,omitempty"` // 心跳间隔（毫秒）
+	Status        *OneBotStatus `json:"status,omitempty"`
+}
+
+// OneBot运行状态
+type OneBotStatus struct {
+	Online bool `json:"online"` // 机器人是否在线
+	Good   bool `json:"good"`   // 状态是否符合预期
+}
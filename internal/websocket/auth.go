@@ -0,0 +1,116 @@
+package websocket
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"grunichat-onebot-adapter/internal/types"
+)
+
+// GRUniChat hello 的认证方式
+const (
+	AuthNone   = "none"   // 不认证，不等待服务端确认
+	AuthToken  = "token"  // hello 中携带令牌
+	AuthHMAC   = "hmac"   // 用密钥签名服务端下发的随机数
+	AuthBearer = "bearer" // 握手请求携带 Authorization: Bearer 请求头
+)
+
+// GRUniChat 握手帧的类型
+const (
+	HandshakeHello     = "hello"
+	HandshakeChallenge = "challenge"    // 服务端下发随机数（hmac方式）
+	HandshakeAck       = "hello_ack"    // 服务端接受hello
+	HandshakeReject    = "hello_reject" // 服务端拒绝hello
+)
+
+// 认证被拒绝或认证配置无效，重试无法恢复
+var ErrAuthFailed = errors.New("GRUniChat authentication failed")
+
+// hmac方式的签名：以密钥计算 clientID + "\n" + nonce 的 HMAC-SHA256，输出十六进制
+func SignNonce(secret, clientID, nonce string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(clientID + "\n" + nonce))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// 检查认证配置
+func validateAuth(mode, token string) error {
+	switch mode {
+	case AuthNone:
+		return nil
+	case AuthToken, AuthHMAC, AuthBearer:
+		if token == "" {
+			return fmt.Errorf("%w: grunichat.auth.token is required for auth mode %q", ErrAuthFailed, mode)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown auth mode %q", ErrAuthFailed, mode)
+	}
+}
+
+// 握手请求被服务端以401/403拒绝时转换为认证失败
+func dialError(err error, resp *http.Response) error {
+	if resp != nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+		return fmt.Errorf("%w: server responded %s", ErrAuthFailed, resp.Status)
+	}
+	return err
+}
+
+// 读取一个握手帧；服务端以策略违规等原因关闭连接时视为拒绝
+func readHandshake(conn *websocket.Conn, timeout time.Duration) (*types.GRUniChatHandshake, error) {
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		var closeErr *websocket.CloseError
+		if errors.As(err, &closeErr) && (closeErr.Code == websocket.ClosePolicyViolation || closeErr.Code >= 4000) {
+			return nil, fmt.Errorf("%w: connection closed by server: %s", ErrAuthFailed, closeErr.Text)
+		}
+		return nil, err
+	}
+
+	var frame types.GRUniChatHandshake
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return nil, fmt.Errorf("invalid handshake frame: %w", err)
+	}
+	return &frame, nil
+}
+
+// 检查服务端对hello的回复
+func checkHelloReply(frame *types.GRUniChatHandshake) error {
+	switch frame.Type {
+	case HandshakeAck:
+		return nil
+	case HandshakeReject:
+		reason := frame.Reason
+		if reason == "" {
+			reason = "no reason given"
+		}
+		return fmt.Errorf("%w: hello rejected: %s", ErrAuthFailed, reason)
+	default:
+		return fmt.Errorf("expected hello acknowledgement, got %q frame (does the server support authentication?)", frame.Type)
+	}
+}
+
+// 解析服务端在连接过程中发来的hello确认或拒绝，其他帧返回nil
+func handshakeReply(message []byte) *types.GRUniChatHandshake {
+	if !bytes.Contains(message, []byte(`"hello_`)) {
+		return nil
+	}
+
+	var frame types.GRUniChatHandshake
+	if json.Unmarshal(message, &frame) != nil || (frame.Type != HandshakeAck && frame.Type != HandshakeReject) {
+		return nil
+	}
+	return &frame
+}
@@ -0,0 +1,161 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gws "github.com/gorilla/websocket"
+
+	"grunichat-onebot-adapter/internal/config"
+	"grunichat-onebot-adapter/internal/types"
+)
+
+func newGRUniChatManager(url, mode, token string) *GRUniChatWebSocketManager {
+	cfg := config.Default()
+	cfg.GRUniChat.URL = url
+	cfg.GRUniChat.ClientID = "survival"
+	cfg.GRUniChat.Auth.Mode = mode
+	cfg.GRUniChat.Auth.Token = token
+	cfg.GRUniChat.Auth.AckTimeout = 2
+	return NewGRUniChatWebSocketManager(cfg, newTestLogger())
+}
+
+// 按 mode 校验hello的服务端，令牌不是 secret 时拒绝
+func authServer(t *testing.T, mode string) string {
+	return newTestServer(t, func(conn *gws.Conn, req *http.Request) {
+		const nonce = "nonce-1"
+		if mode == AuthHMAC {
+			conn.WriteJSON(&types.GRUniChatHandshake{Type: HandshakeChallenge, Nonce: nonce})
+		}
+
+		var hello types.GRUniChatHandshake
+		if err := conn.ReadJSON(&hello); err != nil || hello.Type != HandshakeHello {
+			return
+		}
+
+		var ok bool
+		switch mode {
+		case AuthToken:
+			ok = hello.Token == "secret"
+		case AuthHMAC:
+			ok = hello.Nonce == nonce && hello.Signature == SignNonce("secret", hello.From, nonce)
+		case AuthBearer:
+			ok = req.Header.Get("Authorization") == "Bearer secret"
+		}
+		if !ok {
+			conn.WriteJSON(&types.GRUniChatHandshake{Type: HandshakeReject, Reason: "bad credentials"})
+			return
+		}
+		conn.WriteJSON(&types.GRUniChatHandshake{Type: HandshakeAck})
+		drain(conn)
+	})
+}
+
+func TestConnectAuthModes(t *testing.T) {
+	for _, mode := range []string{AuthToken, AuthHMAC, AuthBearer} {
+		t.Run(mode, func(t *testing.T) {
+			url := authServer(t, mode)
+
+			ws := newGRUniChatManager(url, mode, "secret")
+			if err := ws.Connect(context.Background()); err != nil {
+				t.Fatalf("Connect() error = %v", err)
+			}
+			if !ws.IsConnected() {
+				t.Error("IsConnected() = false after an accepted hello")
+			}
+			ws.Close()
+
+			ws = newGRUniChatManager(url, mode, "wrong")
+			err := ws.Connect(context.Background())
+			if !errors.Is(err, ErrAuthFailed) {
+				t.Fatalf("Connect() error = %v, want ErrAuthFailed", err)
+			}
+			if ws.IsConnected() {
+				t.Error("IsConnected() = true after a rejected hello")
+			}
+		})
+	}
+}
+
+func TestConnectBearerRejectedByStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	ws := newGRUniChatManager("ws"+strings.TrimPrefix(server.URL, "http"), AuthBearer, "secret")
+	if err := ws.Connect(context.Background()); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("Connect() error = %v, want ErrAuthFailed", err)
+	}
+}
+
+func TestConnectRejectsInvalidAuthConfig(t *testing.T) {
+	for _, tt := range []struct{ mode, token string }{
+		{AuthToken, ""},
+		{"password", "secret"},
+	} {
+		ws := newGRUniChatManager("ws://127.0.0.1:1/", tt.mode, tt.token)
+		if err := ws.Connect(context.Background()); !errors.Is(err, ErrAuthFailed) {
+			t.Errorf("Connect(%q, %q) error = %v, want ErrAuthFailed", tt.mode, tt.token, err)
+		}
+	}
+}
+
+func TestHandshakeReply(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{`{"type":"hello_ack"}`, HandshakeAck},
+		{`{"type":"hello_reject","reason":"banned"}`, HandshakeReject},
+		{`{"type":"chat","body":{"chatMessage":"\"hello_ack\""}}`, ""},
+		{`{"type":"hello_`, ""},
+	}
+	for _, tt := range tests {
+		reply := handshakeReply([]byte(tt.message))
+		got := ""
+		if reply != nil {
+			got = reply.Type
+		}
+		if got != tt.want {
+			t.Errorf("handshakeReply(%s) = %q, want %q", tt.message, got, tt.want)
+		}
+	}
+
+	if err := checkHelloReply(&types.GRUniChatHandshake{Type: HandshakeReject}); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("checkHelloReply(reject) = %v, want ErrAuthFailed", err)
+	}
+	if err := checkHelloReply(&types.GRUniChatHandshake{Type: "chat"}); err == nil || errors.Is(err, ErrAuthFailed) {
+		t.Errorf("checkHelloReply(chat) = %v, want a non-auth error", err)
+	}
+}
+
+func TestConnectReturnsRejectionFromReadLoop(t *testing.T) {
+	var dials int32
+	url := newTestServer(t, func(conn *gws.Conn, req *http.Request) {
+		atomic.AddInt32(&dials, 1)
+		conn.ReadJSON(&types.GRUniChatHandshake{})
+		conn.WriteJSON(&types.GRUniChatHandshake{Type: HandshakeReject, Reason: "client revoked"})
+		drain(conn)
+	})
+
+	ws := newGRUniChatManager(url, AuthNone, "")
+	if err := ws.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	waitUntil(t, 2*time.Second, "rejected connection closed", func() bool { return !ws.IsConnected() })
+
+	// 下一次连接直接返回认证失败，不再拨号
+	if err := ws.Connect(context.Background()); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("Connect() error = %v, want ErrAuthFailed", err)
+	}
+	if got := atomic.LoadInt32(&dials); got != 1 {
+		t.Errorf("dials = %d, want 1", got)
+	}
+}
@@ -0,0 +1,95 @@
+package websocket
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"grunichat-onebot-adapter/internal/config"
+)
+
+// 按连接配置创建拨号器；每次连接时重新读取证书文件，更换证书后重连即可生效
+func newDialer(tlsCfg config.TLSConfig, proxy string) (*websocket.Dialer, error) {
+	dialer := &websocket.Dialer{
+		HandshakeTimeout: 10 * time.Second,
+	}
+
+	tlsConfig, err := buildTLSConfig(tlsCfg)
+	if err != nil {
+		return nil, err
+	}
+	dialer.TLSClientConfig = tlsConfig
+
+	// 支持 http:// 和 socks5:// 代理，地址中可以带用户名和密码
+	if proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		switch proxyURL.Scheme {
+		case "http", "socks5":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q, expected http or socks5", proxyURL.Scheme)
+		}
+		dialer.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	return dialer, nil
+}
+
+// 创建TLS配置，未设置任何选项时返回nil（使用系统默认配置）
+func buildTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	if cfg.CAFile == "" && cfg.CertFile == "" && cfg.KeyFile == "" && cfg.ServerName == "" && !cfg.InsecureSkipVerify {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	// 在系统CA的基础上信任额外的CA证书
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid certificates found in CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	// 双向TLS使用的客户端证书
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return nil, fmt.Errorf("both cert_file and key_file are required for a client certificate")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// 将配置中的自定义请求头转换为握手请求头
+func dialHeaders(custom map[string]string) http.Header {
+	headers := http.Header{}
+	for name, value := range custom {
+		headers.Set(name, value)
+	}
+	return headers
+}
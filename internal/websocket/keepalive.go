@@ -0,0 +1,105 @@
+package websocket
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"grunichat-onebot-adapter/internal/config"
+)
+
+// 连接保活：定期发送ping，收到pong或其他消息后延长读取超时；超时未收到任何帧时读取返回错误，视为连接断开
+type keepalive struct {
+	conn         *websocket.Conn
+	pingInterval time.Duration // 为0时不发送ping，也不设置读取超时
+	readTimeout  time.Duration // ping间隔加上等待pong的时间
+	writeTimeout time.Duration
+
+	mu      sync.Mutex
+	stopped bool // 上下文取消后不再延长读取超时
+}
+
+// 按配置创建连接保活
+func newKeepalive(conn *websocket.Conn, cfg config.KeepaliveConfig) *keepalive {
+	k := &keepalive{
+		conn:         conn,
+		writeTimeout: time.Duration(cfg.WriteTimeout) * time.Second,
+	}
+	if cfg.PingInterval > 0 {
+		k.pingInterval = time.Duration(cfg.PingInterval) * time.Second
+		k.readTimeout = k.pingInterval + time.Duration(cfg.PongTimeout)*time.Second
+	}
+	return k
+}
+
+// 启动ping协程，上下文取消时中断阻塞中的读取；返回的函数用于停止ping协程
+func (k *keepalive) start(ctx context.Context) func() {
+	k.extend()
+	k.conn.SetPongHandler(func(string) error {
+		k.extend()
+		return nil
+	})
+
+	done := make(chan struct{})
+	go k.run(ctx, done)
+	return func() { close(done) }
+}
+
+// 定期发送ping，直到上下文取消或读取协程退出
+func (k *keepalive) run(ctx context.Context, done <-chan struct{}) {
+	var ticks <-chan time.Time
+	if k.pingInterval > 0 {
+		ticker := time.NewTicker(k.pingInterval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			k.interrupt()
+			return
+		case <-done:
+			return
+		case <-ticks:
+			// 发送失败时不必处理，对端无响应会导致读取超时
+			k.conn.WriteControl(websocket.PingMessage, nil, k.writeDeadline())
+		}
+	}
+}
+
+// 收到帧后延长读取超时
+func (k *keepalive) extend() {
+	if k.pingInterval == 0 {
+		return
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if !k.stopped {
+		k.conn.SetReadDeadline(time.Now().Add(k.readTimeout))
+	}
+}
+
+// 将读取超时设为当前时间，使阻塞中的读取立即返回
+func (k *keepalive) interrupt() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.stopped = true
+	k.conn.SetReadDeadline(time.Now())
+}
+
+// 本次写入的截止时间，未配置写入超时时返回零值（不限制）
+func (k *keepalive) writeDeadline() time.Time {
+	return writeDeadline(k.writeTimeout)
+}
+
+// 按超时时间计算写入截止时间
+func writeDeadline(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(timeout)
+}
@@ -0,0 +1,119 @@
+package websocket
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gws "github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+
+	"grunichat-onebot-adapter/internal/config"
+)
+
+// 启动测试用WebSocket服务端，每个连接交给 handle 处理，返回ws地址
+func newTestServer(t *testing.T, handle func(conn *gws.Conn, req *http.Request)) string {
+	t.Helper()
+	upgrader := gws.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		conn, err := upgrader.Upgrade(w, req, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handle(conn, req)
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// 读取直到连接关闭，保持连接
+func drain(conn *gws.Conn) {
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func newTestLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func newOneBotManager(url string, keepalive config.KeepaliveConfig) *OneBotWebSocketManager {
+	cfg := config.Default()
+	cfg.OneBot.WebSocketURL = url
+	cfg.OneBot.Keepalive = keepalive
+	return NewOneBotWebSocketManager(cfg, newTestLogger())
+}
+
+// 轮询等待条件满足
+func waitUntil(t *testing.T, timeout time.Duration, what string, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestKeepaliveDetectsDeadPeer(t *testing.T) {
+	url := newTestServer(t, func(conn *gws.Conn, req *http.Request) {
+		conn.SetPingHandler(func(string) error { return nil }) // 不回复pong
+		drain(conn)
+	})
+
+	ws := newOneBotManager(url, config.KeepaliveConfig{PingInterval: 1, PongTimeout: 1, WriteTimeout: 1})
+	if err := ws.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer ws.Close()
+
+	waitUntil(t, 5*time.Second, "keepalive timeout", func() bool { return !ws.IsConnected() })
+	if err := ws.SendMessage(map[string]string{"action": "get_status"}); err == nil {
+		t.Error("SendMessage() succeeded on a dead connection")
+	}
+}
+
+func TestKeepaliveKeepsLivePeer(t *testing.T) {
+	url := newTestServer(t, func(conn *gws.Conn, req *http.Request) {
+		drain(conn) // 默认的ping处理器会回复pong
+	})
+
+	ws := newOneBotManager(url, config.KeepaliveConfig{PingInterval: 1, PongTimeout: 1, WriteTimeout: 1})
+	if err := ws.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer ws.Close()
+
+	// 超过 ping间隔 + pong超时 后仍然保持连接
+	time.Sleep(2500 * time.Millisecond)
+	if !ws.IsConnected() {
+		t.Fatal("keepalive dropped a peer that answers pings")
+	}
+}
+
+func TestKeepaliveStopsReadLoopOnCancel(t *testing.T) {
+	url := newTestServer(t, func(conn *gws.Conn, req *http.Request) {
+		drain(conn)
+	})
+
+	ws := newOneBotManager(url, config.KeepaliveConfig{})
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := ws.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer ws.Close()
+
+	// 未启用ping时，取消上下文也要中断阻塞中的读取
+	cancel()
+	waitUntil(t, 2*time.Second, "read loop exit", func() bool { return !ws.IsConnected() })
+}
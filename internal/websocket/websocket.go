@@ -2,14 +2,17 @@ package websocket
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"net/http"
+	"net"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/sirupsen/logrus"
 
 	"grunichat-onebot-adapter/internal/config"
+	"grunichat-onebot-adapter/internal/types"
 )
 
 // WebSocket管理器接口
@@ -25,6 +28,8 @@ type IWebSocketManager interface {
 type OneBotWebSocketManager struct {
 	config         *config.Config
 	logger         *logrus.Logger
+	mu             sync.RWMutex // 保护 conn、handler、connected
+	writeMu        sync.Mutex   // 同一时间只能有一个协程写入连接
 	conn           *websocket.Conn
 	handler        func(message []byte)
 	connected      bool
@@ -44,81 +49,107 @@ func NewOneBotWebSocketManager(cfg *config.Config, logger *logrus.Logger) *OneBo
 func (ws *OneBotWebSocketManager) Connect(ctx context.Context) error {
 	wsURL := ws.config.OneBot.WebSocketURL
 
-	dialer := websocket.Dialer{
-		HandshakeTimeout: 10 * time.Second,
+	dialer, err := newDialer(ws.config.OneBot.TLS, ws.config.OneBot.Proxy)
+	if err != nil {
+		return fmt.Errorf("invalid OneBot connection settings: %w", err)
+	}
+	if ws.config.OneBot.TLS.InsecureSkipVerify {
+		ws.logger.Warn("TLS certificate verification is disabled for OneBot")
 	}
 
-	headers := http.Header{}
+	headers := dialHeaders(ws.config.OneBot.Headers)
 	if ws.config.OneBot.AccessToken != "" {
 		headers.Set("Authorization", "Bearer "+ws.config.OneBot.AccessToken)
 	}
 
 	ws.logger.Infof("Connecting to OneBot at %s", wsURL)
 
-	conn, _, err := dialer.Dial(wsURL, headers)
+	conn, _, err := dialer.DialContext(ctx, wsURL, headers)
 	if err != nil {
 		return fmt.Errorf("failed to connect to OneBot: %w", err)
 	}
 
+	ws.mu.Lock()
 	ws.conn = conn
 	ws.connected = true
+	ws.mu.Unlock()
 	ws.logger.Info("Connected to OneBot WebSocket")
 
 	// 启动消息读取协程
-	go ws.readMessages(ctx)
+	go ws.readMessages(ctx, conn)
 
 	return nil
 }
 
 // 发送消息到OneBot
 func (ws *OneBotWebSocketManager) SendMessage(message interface{}) error {
-	if !ws.connected || ws.conn == nil {
+	ws.mu.RLock()
+	conn, connected := ws.conn, ws.connected
+	ws.mu.RUnlock()
+
+	if !connected || conn == nil {
 		return fmt.Errorf("OneBot WebSocket not connected")
 	}
 
-	return ws.conn.WriteJSON(message)
+	return writeJSON(&ws.writeMu, conn, message, ws.config.OneBot.Keepalive)
 }
 
 // 设置消息处理器
 func (ws *OneBotWebSocketManager) SetMessageHandler(handler func(message []byte)) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
 	ws.handler = handler
 }
 
 // 关闭连接
 func (ws *OneBotWebSocketManager) Close() error {
+	ws.mu.Lock()
+	conn := ws.conn
 	ws.connected = false
-	if ws.conn != nil {
-		return ws.conn.Close()
+	ws.mu.Unlock()
+
+	if conn != nil {
+		closeConnection(conn)
+		return conn.Close()
 	}
 	return nil
 }
 
 // 检查连接状态
 func (ws *OneBotWebSocketManager) IsConnected() bool {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
 	return ws.connected
 }
 
 // 读取消息协程
-func (ws *OneBotWebSocketManager) readMessages(ctx context.Context) {
+func (ws *OneBotWebSocketManager) readMessages(ctx context.Context, conn *websocket.Conn) {
 	defer func() {
-		ws.connected = false
+		// 重连后旧的读取协程退出时不影响新连接的状态
+		ws.mu.Lock()
+		if ws.conn == conn {
+			ws.connected = false
+		}
+		ws.mu.Unlock()
 	}()
 
+	alive := newKeepalive(conn, ws.config.OneBot.Keepalive)
+	defer alive.start(ctx)()
+
 	for {
-		select {
-		case <-ctx.Done():
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			logReadError(ctx, ws.logger, "OneBot", err)
+			closeAfterReadError(ctx, conn)
 			return
-		default:
-			_, message, err := ws.conn.ReadMessage()
-			if err != nil {
-				ws.logger.Errorf("OneBot WebSocket read error: %v", err)
-				ws.connected = false
-				return
-			}
+		}
+		alive.extend()
 
-			if ws.handler != nil {
-				ws.handler(message)
-			}
+		ws.mu.RLock()
+		handler := ws.handler
+		ws.mu.RUnlock()
+		if handler != nil {
+			handler(message)
 		}
 	}
 }
@@ -127,9 +158,12 @@ func (ws *OneBotWebSocketManager) readMessages(ctx context.Context) {
 type GRUniChatWebSocketManager struct {
 	config         *config.Config
 	logger         *logrus.Logger
+	mu             sync.RWMutex // 保护 conn、handler、connected、rejected
+	writeMu        sync.Mutex   // 同一时间只能有一个协程写入连接
 	conn           *websocket.Conn
 	handler        func(message []byte)
 	connected      bool
+	rejected       error // 连接建立后服务端拒绝hello的原因，由下一次 Connect 返回
 	reconnectDelay time.Duration
 }
 
@@ -146,106 +180,237 @@ func NewGRUniChatWebSocketManager(cfg *config.Config, logger *logrus.Logger) *GR
 func (ws *GRUniChatWebSocketManager) Connect(ctx context.Context) error {
 	wsURL := ws.config.GRUniChat.URL
 
-	dialer := websocket.Dialer{
-		HandshakeTimeout: 10 * time.Second,
+	// 上一个连接的hello被拒绝时，重连也会被拒绝，直接返回认证失败由调用方停止重试
+	ws.mu.Lock()
+	rejected := ws.rejected
+	ws.rejected = nil
+	ws.mu.Unlock()
+	if rejected != nil {
+		return rejected
+	}
+
+	dialer, err := newDialer(ws.config.GRUniChat.TLS, ws.config.GRUniChat.Proxy)
+	if err != nil {
+		return fmt.Errorf("invalid GRUniChat connection settings: %w", err)
+	}
+	if ws.config.GRUniChat.TLS.InsecureSkipVerify {
+		ws.logger.Warn("TLS certificate verification is disabled for GRUniChat")
+	}
+
+	auth := ws.config.GRUniChat.Auth
+	if err := validateAuth(auth.Mode, auth.Token); err != nil {
+		return err
+	}
+	headers := dialHeaders(ws.config.GRUniChat.Headers)
+	if auth.Mode == AuthBearer {
+		headers.Set("Authorization", "Bearer "+auth.Token)
 	}
 
 	ws.logger.Infof("Connecting to GRUniChat at %s", wsURL)
 
-	conn, _, err := dialer.Dial(wsURL, nil)
+	conn, resp, err := dialer.DialContext(ctx, wsURL, headers)
 	if err != nil {
-		return fmt.Errorf("failed to connect to GRUniChat: %w", err)
+		return fmt.Errorf("failed to connect to GRUniChat: %w", dialError(err, resp))
 	}
-
-	ws.conn = conn
-	ws.connected = true
 	ws.logger.Info("Connected to GRUniChat WebSocket")
 
 	// 发送hello消息进行认证
-	if err := ws.sendHelloMessage(); err != nil {
-		ws.logger.Errorf("Failed to send hello message: %v", err)
+	if err := ws.sendHelloMessage(conn); err != nil {
+		ws.logger.Errorf("GRUniChat handshake failed: %v", err)
 		conn.Close()
-		ws.connected = false
 		return err
 	}
 
+	ws.mu.Lock()
+	ws.conn = conn
+	ws.connected = true
+	ws.mu.Unlock()
+
 	// 启动消息读取协程
-	go ws.readMessages(ctx)
+	go ws.readMessages(ctx, conn)
 
 	return nil
 }
 
-// 发送hello认证消息
-func (ws *GRUniChatWebSocketManager) sendHelloMessage() error {
-	helloMsg := map[string]interface{}{
-		"type": "hello",
-		"from": ws.config.GRUniChat.ClientID,
+// 发送hello认证消息；启用认证时等待服务端确认，被拒绝时返回 ErrAuthFailed
+func (ws *GRUniChatWebSocketManager) sendHelloMessage(conn *websocket.Conn) error {
+	auth := ws.config.GRUniChat.Auth
+	timeout := time.Duration(auth.AckTimeout) * time.Second
+	helloMsg := &types.GRUniChatHandshake{
+		Type: HandshakeHello,
+		From: ws.config.GRUniChat.ClientID,
 	}
 
-	ws.logger.Debugf("Sending hello message: %+v", helloMsg)
+	switch auth.Mode {
+	case AuthToken:
+		helloMsg.Token = auth.Token
+	case AuthHMAC:
+		challenge, err := readHandshake(conn, timeout)
+		if err != nil {
+			return fmt.Errorf("failed to read challenge: %w", err)
+		}
+		if challenge.Type != HandshakeChallenge || challenge.Nonce == "" {
+			return fmt.Errorf("expected challenge with nonce, got %q frame", challenge.Type)
+		}
+		helloMsg.Nonce = challenge.Nonce
+		helloMsg.Signature = SignNonce(auth.Token, helloMsg.From, challenge.Nonce)
+	}
+
+	ws.logger.Debugf("Sending hello message as %s (auth: %s)", helloMsg.From, auth.Mode)
 
-	if err := ws.conn.WriteJSON(helloMsg); err != nil {
+	if err := writeJSON(&ws.writeMu, conn, helloMsg, ws.config.GRUniChat.Keepalive); err != nil {
 		return fmt.Errorf("failed to send hello message: %w", err)
 	}
 
 	ws.logger.Debug("Hello message sent successfully")
+	if auth.Mode == AuthNone {
+		return nil
+	}
+
+	reply, err := readHandshake(conn, timeout)
+	if err != nil {
+		return fmt.Errorf("failed to read hello acknowledgement: %w", err)
+	}
+	if err := checkHelloReply(reply); err != nil {
+		return err
+	}
+	ws.logger.Infof("GRUniChat accepted %s authentication", auth.Mode)
 	return nil
 }
 
 // 读取消息协程
-func (ws *GRUniChatWebSocketManager) readMessages(ctx context.Context) {
+func (ws *GRUniChatWebSocketManager) readMessages(ctx context.Context, conn *websocket.Conn) {
 	defer func() {
-		ws.connected = false
+		// 重连后旧的读取协程退出时不影响新连接的状态
+		ws.mu.Lock()
+		if ws.conn == conn {
+			ws.connected = false
+		}
+		ws.mu.Unlock()
 	}()
 
+	alive := newKeepalive(conn, ws.config.GRUniChat.Keepalive)
+	defer alive.start(ctx)()
+
 	for {
-		select {
-		case <-ctx.Done():
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			logReadError(ctx, ws.logger, "GRUniChat", err)
+			closeAfterReadError(ctx, conn)
 			return
-		default:
-			_, message, err := ws.conn.ReadMessage()
-			if err != nil {
-				ws.logger.Errorf("GRUniChat WebSocket read error: %v", err)
-				ws.connected = false
+		}
+		alive.extend()
+
+		// 未启用认证时服务端也可能回复确认或拒绝，这些帧不交给消息处理器
+		if reply := handshakeReply(message); reply != nil {
+			if err := checkHelloReply(reply); err != nil {
+				ws.logger.Errorf("Closing GRUniChat connection: %v", err)
+				ws.mu.Lock()
+				if ws.conn == conn {
+					ws.rejected = err
+				}
+				ws.mu.Unlock()
+				conn.Close()
 				return
 			}
+			ws.logger.Debug("GRUniChat acknowledged hello")
+			continue
+		}
 
-			if ws.handler != nil {
-				ws.handler(message)
-			}
+		ws.mu.RLock()
+		handler := ws.handler
+		ws.mu.RUnlock()
+		if handler != nil {
+			handler(message)
 		}
 	}
 }
 
 // 发送消息到GRUniChat
 func (ws *GRUniChatWebSocketManager) SendMessage(message interface{}) error {
-	if !ws.connected || ws.conn == nil {
-		ws.logger.Warn("GRUniChat client not connected, skipping message")
-		return nil // 不返回错误，因为客户端可能没有连接
+	ws.mu.RLock()
+	conn, connected := ws.conn, ws.connected
+	ws.mu.RUnlock()
+
+	if !connected || conn == nil {
+		return fmt.Errorf("GRUniChat WebSocket not connected")
 	}
 
-	return ws.conn.WriteJSON(message)
+	return writeJSON(&ws.writeMu, conn, message, ws.config.GRUniChat.Keepalive)
 }
 
 // 设置消息处理器
 func (ws *GRUniChatWebSocketManager) SetMessageHandler(handler func(message []byte)) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
 	ws.handler = handler
 }
 
 // 关闭连接
 func (ws *GRUniChatWebSocketManager) Close() error {
+	ws.mu.Lock()
+	conn := ws.conn
 	ws.connected = false
-	if ws.conn != nil {
-		return ws.conn.Close()
+	ws.mu.Unlock()
+
+	if conn != nil {
+		closeConnection(conn)
+		return conn.Close()
 	}
 	return nil
 }
 
 // 检查连接状态
 func (ws *GRUniChatWebSocketManager) IsConnected() bool {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
 	return ws.connected
 }
 
+// 带写入超时地发送JSON消息；写入失败后连接不可再用，关闭连接使读取协程退出并触发重连
+func writeJSON(writeMu *sync.Mutex, conn *websocket.Conn, message interface{}, keepalive config.KeepaliveConfig) error {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
+	conn.SetWriteDeadline(writeDeadline(time.Duration(keepalive.WriteTimeout) * time.Second))
+	if err := conn.WriteJSON(message); err != nil {
+		conn.Close()
+		return err
+	}
+	return nil
+}
+
+// 读取出错后关闭连接；上下文取消时由 Close 发送关闭帧后再关闭
+func closeAfterReadError(ctx context.Context, conn *websocket.Conn) {
+	if ctx.Err() == nil {
+		conn.Close()
+	}
+}
+
+// 记录读取协程退出的原因，主动关闭和对端正常关闭不作为错误
+func logReadError(ctx context.Context, logger *logrus.Logger, name string, err error) {
+	var netErr net.Error
+	switch {
+	case ctx.Err() != nil || errors.Is(err, net.ErrClosed):
+		logger.Debugf("%s WebSocket read loop stopped: %v", name, err)
+	case websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway):
+		logger.Infof("%s WebSocket closed by peer: %v", name, err)
+	case errors.As(err, &netErr) && netErr.Timeout():
+		logger.Warnf("%s WebSocket keepalive timed out, treating connection as lost: %v", name, err)
+	default:
+		logger.Errorf("%s WebSocket read error: %v", name, err)
+	}
+}
+
+// 发送关闭帧的超时时间
+const closeWriteTimeout = time.Second
+
+// 发送正常关闭帧，通知对端连接是主动关闭的
+func closeConnection(conn *websocket.Conn) {
+	message := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(closeWriteTimeout))
+}
+
 // WebSocket管理器工厂
 type WebSocketManagerFactory struct {
 	config *config.Config
@@ -8,13 +8,17 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"grunichat-onebot-adapter/internal/adapter"
 	"grunichat-onebot-adapter/internal/config"
+	"grunichat-onebot-adapter/internal/recorder"
+	"grunichat-onebot-adapter/internal/replay"
 )
 
 var (
@@ -99,10 +103,12 @@ func main() {
 	// 解析命令行参数
 	configPath := flag.String("config", "./config.yaml", "配置文件路径")
 	noCheckUpdate := flag.Bool("no-check-update", false, "跳过版本更新检查")
+	replayPath := flag.String("replay", "", "回放流量记录文件，并与记录的输出进行比较")
+	replaySpeed := flag.Float64("replay-speed", 1, "回放速度倍数，0表示不等待")
 	flag.Parse()
 
-	// 检查版本更新（除非用户明确跳过）
-	if !*noCheckUpdate {
+	// 检查版本更新（除非用户明确跳过或处于回放模式）
+	if !*noCheckUpdate && *replayPath == "" {
 		checkForUpdates()
 	}
 
@@ -165,11 +171,22 @@ func main() {
 		}
 	}
 
+	// 收到 SIGINT/SIGTERM 时取消上下文；取消后恢复默认的信号处理，关闭期间再次按 Ctrl+C 可以立即退出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	// 回放模式：连接模拟服务端回放记录，输出差异后退出
+	if *replayPath != "" {
+		os.Exit(runReplay(ctx, cfg, logger, *replayPath, *replaySpeed))
+	}
+
 	fmt.Println("正在启动 GRUniChat-OneBot 模块化适配器...")
 	logger.Info("Starting GRUniChat-OneBot Modular Adapter")
 
-	// 创建并启动模块化适配器
-	ctx := context.Background()
+	// 创建并启动模块化适配器，阻塞到收到关闭信号
 	adapterInstance := adapter.NewModularAdapter(cfg, logger)
 	if err := adapterInstance.Start(ctx); err != nil {
 		logger.Fatalf("Failed to start adapter: %v", err)
@@ -178,3 +195,31 @@ func main() {
 	// 检查版本更新
 	checkForUpdates()
 }
+
+// 回放流量记录，输出与记录一致时返回0
+func runReplay(ctx context.Context, cfg *config.Config, logger *logrus.Logger, path string, speed float64) int {
+	frames, err := recorder.Load(path)
+	if err != nil {
+		fmt.Printf("读取流量记录失败: %v\n", err)
+		return 2
+	}
+
+	fmt.Printf("正在回放流量记录: %s（%d 帧）\n", path, len(frames))
+	result, err := replay.Run(ctx, cfg, logger, frames, replay.Options{
+		Speed:  speed,
+		MaxGap: 5 * time.Second,
+		Settle: 5 * time.Second,
+	})
+	if err != nil {
+		fmt.Printf("回放失败: %v\n", err)
+		return 2
+	}
+
+	result.Write(os.Stdout)
+	if !result.OK() {
+		fmt.Println("回放结果与记录不一致")
+		return 1
+	}
+	fmt.Println("回放结果与记录一致")
+	return 0
+}
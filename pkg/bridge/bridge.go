@@ -0,0 +1,238 @@
+// Package bridge 是嵌入GRUniChat-OneBot适配器的公开接口。
+//
+// 生命周期：
+//
+//	b, err := bridge.New(cfg, bridge.WithLogger(logger))
+//	if err := b.Start(ctx); err != nil { ... } // 连接成功后返回，不处理系统信号
+//	...
+//	b.Stop()                                   // 或等待 ctx 取消后 b.Wait()
+//
+// Start 只能调用一次；ctx 取消或调用 Stop 后适配器关闭，Wait 返回关闭结果。
+package bridge
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"grunichat-onebot-adapter/internal/adapter"
+	"grunichat-onebot-adapter/internal/config"
+	"grunichat-onebot-adapter/internal/converter"
+	"grunichat-onebot-adapter/internal/formatter"
+	"grunichat-onebot-adapter/internal/middleware"
+	"grunichat-onebot-adapter/internal/types"
+	"grunichat-onebot-adapter/internal/websocket"
+)
+
+// 公开的类型
+type (
+	Config            = config.Config
+	IWebSocketManager = websocket.IWebSocketManager
+	GRUniChatMessage  = types.GRUniChatMessage
+	GRUniChatBody     = types.GRUniChatBody
+	OneBotMessage     = types.OneBotMessage
+	OneBotSender      = types.OneBotSender
+	Envelope          = middleware.Envelope
+	Handler           = middleware.Handler
+	Middleware        = middleware.Middleware
+	MessageConverter  = converter.MessageConverter
+	MessageFormatter  = formatter.MessageFormatter
+)
+
+// 消息方向
+const (
+	DirectionInbound  = middleware.DirectionInbound  // OneBot → GRUniChat
+	DirectionOutbound = middleware.DirectionOutbound // GRUniChat → OneBot
+)
+
+var (
+	ErrAlreadyStarted = errors.New("bridge already started")
+	ErrNotStarted     = errors.New("bridge not started")
+)
+
+// 消息钩子，可以修改信封中的消息；返回false时丢弃该消息
+type Hook func(env *Envelope) bool
+
+// 加载配置文件
+func LoadConfig(path string) (*Config, error) {
+	return config.LoadConfig(path)
+}
+
+// 返回默认配置，与自动创建的配置文件内容相同
+func DefaultConfig() *Config {
+	return config.Default()
+}
+
+// 注册自定义处理阶段，需在 New 之前调用，并在配置的 middleware.inbound / outbound 中按名称引用
+func RegisterStage(name string, mw Middleware) {
+	middleware.Register(name, mw)
+}
+
+// 创建独立使用的消息格式化器
+func NewMessageFormatter(cfg *Config, logger *logrus.Logger) *MessageFormatter {
+	return formatter.NewMessageFormatter(cfg, logger)
+}
+
+// 可选项
+type Option func(*options)
+
+type options struct {
+	logger  *logrus.Logger
+	adapter adapter.Options
+}
+
+// 使用指定的日志记录器，默认为 logrus.New()
+func WithLogger(logger *logrus.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// 使用自定义的OneBot连接
+func WithOneBotTransport(transport IWebSocketManager) Option {
+	return func(o *options) {
+		o.adapter.OneBotTransport = transport
+	}
+}
+
+// 使用自定义的GRUniChat连接
+func WithGRUniChatTransport(transport IWebSocketManager) Option {
+	return func(o *options) {
+		o.adapter.GRUniChatTransport = transport
+	}
+}
+
+// 入站消息（QQ→游戏）在配置的处理阶段之后、发送到GRUniChat之前调用钩子，env.GRUniChat 为将要发送的消息
+func WithInboundHook(hook Hook) Option {
+	return func(o *options) {
+		o.adapter.InboundHooks = append(o.adapter.InboundHooks, hookMiddleware(hook))
+	}
+}
+
+// 出站消息（游戏→QQ）在配置的处理阶段之后、发送到QQ群之前调用钩子，env.Text 为将要发送的文本
+func WithOutboundHook(hook Hook) Option {
+	return func(o *options) {
+		o.adapter.OutboundHooks = append(o.adapter.OutboundHooks, hookMiddleware(hook))
+	}
+}
+
+// 在配置的入站阶段之后追加中间件
+func WithInboundMiddleware(mw Middleware) Option {
+	return func(o *options) {
+		o.adapter.InboundHooks = append(o.adapter.InboundHooks, mw)
+	}
+}
+
+// 在配置的出站阶段之后追加中间件
+func WithOutboundMiddleware(mw Middleware) Option {
+	return func(o *options) {
+		o.adapter.OutboundHooks = append(o.adapter.OutboundHooks, mw)
+	}
+}
+
+// 将钩子包装为中间件
+func hookMiddleware(hook Hook) Middleware {
+	return func(ctx context.Context, env *Envelope, next Handler) error {
+		if !hook(env) {
+			return nil
+		}
+		return next(ctx, env)
+	}
+}
+
+// 嵌入的适配器实例
+type Bridge struct {
+	adapter *adapter.ModularAdapter
+
+	mu      sync.Mutex
+	started bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+	err     error
+}
+
+// 创建适配器实例
+func New(cfg *Config, opts ...Option) (*Bridge, error) {
+	if cfg == nil {
+		return nil, errors.New("config is required")
+	}
+
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.logger == nil {
+		o.logger = logrus.New()
+	}
+
+	return &Bridge{
+		adapter: adapter.NewModularAdapterWithOptions(cfg, o.logger, o.adapter),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// 启动适配器，连接成功后返回；ctx 取消时适配器关闭
+func (b *Bridge) Start(ctx context.Context) error {
+	b.mu.Lock()
+	if b.started {
+		b.mu.Unlock()
+		return ErrAlreadyStarted
+	}
+	b.started = true
+	runCtx, cancel := context.WithCancel(ctx)
+	b.cancel = cancel
+	b.mu.Unlock()
+
+	if err := b.adapter.Launch(runCtx); err != nil {
+		cancel()
+		b.adapter.Shutdown()
+		b.err = err
+		close(b.done)
+		return err
+	}
+
+	go func() {
+		<-runCtx.Done()
+		b.err = b.adapter.Shutdown()
+		close(b.done)
+	}()
+	return nil
+}
+
+// 停止适配器并等待关闭完成
+func (b *Bridge) Stop() error {
+	b.mu.Lock()
+	cancel := b.cancel
+	b.mu.Unlock()
+
+	if cancel == nil {
+		return ErrNotStarted
+	}
+	cancel()
+	return b.Wait()
+}
+
+// 等待适配器关闭，返回关闭过程中的错误
+func (b *Bridge) Wait() error {
+	b.mu.Lock()
+	started := b.started
+	b.mu.Unlock()
+
+	if !started {
+		return ErrNotStarted
+	}
+	<-b.done
+	return b.err
+}
+
+// 适配器使用的消息转换器
+func (b *Bridge) Converter() *MessageConverter {
+	return b.adapter.Converter()
+}
+
+// 适配器使用的消息格式化器
+func (b *Bridge) Formatter() *MessageFormatter {
+	return b.adapter.Formatter()
+}
@@ -0,0 +1,145 @@
+package bridge_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"grunichat-onebot-adapter/internal/sim/grunichatsim"
+	"grunichat-onebot-adapter/internal/sim/onebotsim"
+	"grunichat-onebot-adapter/internal/websocket"
+	"grunichat-onebot-adapter/pkg/bridge"
+)
+
+const testTimeout = 3 * time.Second
+
+// 连接到两个模拟服务端的配置
+func simulatorConfig(t *testing.T) (*bridge.Config, *onebotsim.Server, *grunichatsim.Server) {
+	t.Helper()
+	onebot := onebotsim.NewServer()
+	t.Cleanup(onebot.Close)
+	grunichat := grunichatsim.NewServer()
+	t.Cleanup(grunichat.Close)
+
+	cfg := bridge.DefaultConfig()
+	cfg.OneBot.WebSocketURL = onebot.URL()
+	cfg.GRUniChat.URL = grunichat.URL()
+	cfg.GRUniChat.ReconnectInterval = 1
+	cfg.GRUniChat.MaxReconnectAttempts = 1
+	cfg.Filter.ServiceGroups = []int64{100}
+	cfg.Shutdown.Timeout = 1
+	return cfg, onebot, grunichat
+}
+
+func newTestLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func TestBridgeLifecycle(t *testing.T) {
+	cfg, onebot, grunichat := simulatorConfig(t)
+	b, err := bridge.New(cfg, bridge.WithLogger(newTestLogger()), bridge.WithOutboundHook(func(env *bridge.Envelope) bool {
+		env.Text += " (bridged)"
+		return true
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Stop(); !errors.Is(err, bridge.ErrNotStarted) {
+		t.Errorf("Stop() before Start() = %v, want ErrNotStarted", err)
+	}
+	if err := b.Wait(); !errors.Is(err, bridge.ErrNotStarted) {
+		t.Errorf("Wait() before Start() = %v, want ErrNotStarted", err)
+	}
+
+	if err := b.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if err := b.Start(context.Background()); !errors.Is(err, bridge.ErrAlreadyStarted) {
+		t.Errorf("second Start() = %v, want ErrAlreadyStarted", err)
+	}
+
+	// Start 返回时已连接，可以直接转发消息
+	if err := grunichat.WaitForClient(testTimeout); err != nil {
+		t.Fatal(err)
+	}
+	if err := grunichat.SendChat("Alex", "hi", "group_100"); err != nil {
+		t.Fatal(err)
+	}
+	action, err := onebot.WaitForNextAction("send_group_msg", testTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := action.Message(); !strings.HasSuffix(got, " (bridged)") {
+		t.Errorf("message = %q, want the outbound hook applied", got)
+	}
+
+	if err := b.Stop(); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+	// 关闭后 Wait 立即返回相同的结果
+	if err := b.Wait(); err != nil {
+		t.Errorf("Wait() after Stop() = %v", err)
+	}
+	if err := b.Start(context.Background()); !errors.Is(err, bridge.ErrAlreadyStarted) {
+		t.Errorf("Start() after Stop() = %v, want ErrAlreadyStarted", err)
+	}
+}
+
+func TestBridgeStopsWhenContextIsCancelled(t *testing.T) {
+	cfg, _, _ := simulatorConfig(t)
+	b, err := bridge.New(cfg, bridge.WithLogger(newTestLogger()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := b.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- b.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Wait() error = %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Wait() did not return after the context was cancelled")
+	}
+}
+
+func TestBridgeStartFailure(t *testing.T) {
+	cfg, _, grunichat := simulatorConfig(t)
+	grunichat.RequireAuth(websocket.AuthToken, "secret")
+	cfg.GRUniChat.Auth.Mode = websocket.AuthToken
+	cfg.GRUniChat.Auth.Token = "wrong"
+
+	b, err := bridge.New(cfg, bridge.WithLogger(newTestLogger()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	startErr := b.Start(context.Background())
+	if !errors.Is(startErr, websocket.ErrAuthFailed) {
+		t.Fatalf("Start() error = %v, want ErrAuthFailed", startErr)
+	}
+	// 启动失败后 Wait 返回启动错误，不会阻塞
+	if err := b.Wait(); !errors.Is(err, websocket.ErrAuthFailed) {
+		t.Errorf("Wait() after a failed Start() = %v, want ErrAuthFailed", err)
+	}
+}
+
+func TestNewRequiresConfig(t *testing.T) {
+	if _, err := bridge.New(nil); err == nil {
+		t.Error("New(nil) error = nil")
+	}
+}